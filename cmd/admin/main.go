@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github-notes-backend/internal/config"
+	"github-notes-backend/internal/database"
+	"github-notes-backend/internal/models"
+	"github-notes-backend/internal/services"
+
+	"github.com/google/uuid"
+)
+
+const usage = `Usage: admin <command> [flags]
+
+Commands:
+  resolve-repos        Re-resolve every known GitHub repository and follow renames and transfers
+  webhook-deliveries   List logged webhook deliveries (-status, -event, -limit)
+  webhook-show         Print a logged delivery with its payload: webhook-show <delivery-id>
+  webhook-replay       Apply a logged delivery again: webhook-replay <delivery-id>
+                       or a recorded payload: webhook-replay -event pull_request -file payload.json
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "resolve-repos":
+		resolveRepos(os.Args[2:])
+	case "webhook-deliveries":
+		listWebhookDeliveries(os.Args[2:])
+	case "webhook-show":
+		showWebhookDelivery(os.Args[2:])
+	case "webhook-replay":
+		replayWebhook(os.Args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+// resolveRepos re-reads every GitHub repository in the registry. Each one is
+// fetched with -token (or GITHUB_TOKEN) when given, otherwise with the tokens
+// of users who have notes in it until one of them can see the repository.
+func resolveRepos(args []string) {
+	fs := flag.NewFlagSet("resolve-repos", flag.ExitOnError)
+	token := fs.String("token", os.Getenv("GITHUB_TOKEN"), "GitHub token used for every repository")
+	fs.Parse(args)
+
+	connect()
+
+	var repos []models.Repository
+	if err := database.DB.Where("provider = ?", models.ProviderGitHub).Order("owner, name").Find(&repos).Error; err != nil {
+		log.Fatal("Failed to load repositories:", err)
+	}
+
+	github := services.NewGitHubService()
+	var moved, failed int
+	for i := range repos {
+		repo := &repos[i]
+
+		tokens := []string{*token}
+		if *token == "" {
+			tokens = repositoryTokens(repo)
+		}
+		if len(tokens) == 0 {
+			log.Printf("%s: skipped, no token available", repo.FullName())
+			failed++
+			continue
+		}
+
+		var err error
+		for _, t := range tokens {
+			var repoMoved bool
+			if repoMoved, err = services.ResolveRepository(github, repo, t); err == nil {
+				if repoMoved {
+					moved++
+				}
+				break
+			}
+		}
+		if err != nil {
+			log.Printf("%s: %v", repo.FullName(), err)
+			failed++
+		}
+	}
+
+	log.Printf("Resolved %d repositories: %d moved, %d failed", len(repos), moved, failed)
+}
+
+// repositoryTokens returns the GitHub tokens of users with notes in repo.
+func repositoryTokens(repo *models.Repository) []string {
+	var tokens []string
+	database.DB.Model(&models.User{}).
+		Distinct("users.github_token").
+		Joins("JOIN notes ON notes.user_id = users.id").
+		Where("notes.repository_id = ? AND users.github_token <> ''", repo.ID).
+		Pluck("users.github_token", &tokens)
+	return tokens
+}
+
+// listWebhookDeliveries prints the most recent webhook deliveries.
+func listWebhookDeliveries(args []string) {
+	fs := flag.NewFlagSet("webhook-deliveries", flag.ExitOnError)
+	status := fs.String("status", "", "only deliveries with this status (processed, ignored, failed)")
+	event := fs.String("event", "", "only deliveries of this event")
+	limit := fs.Int("limit", 50, "number of deliveries to list")
+	fs.Parse(args)
+
+	connect()
+
+	query := database.DB.Order("created_at DESC").Limit(*limit)
+	if *status != "" {
+		query = query.Where("status = ?", *status)
+	}
+	if *event != "" {
+		query = query.Where("event = ?", *event)
+	}
+
+	var deliveries []models.WebhookDelivery
+	if err := query.Find(&deliveries).Error; err != nil {
+		log.Fatal("Failed to load deliveries:", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "DELIVERY\tRECEIVED\tEVENT\tACTION\tREPOSITORY\tSTATUS\tATTEMPTS\tSUMMARY")
+	for _, d := range deliveries {
+		summary := d.Summary
+		if d.Error != "" {
+			summary = d.Error
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%d\t%s\n", d.DeliveryID, d.CreatedAt.Format(time.RFC3339),
+			d.Event, d.Action, d.Repository, d.Status, d.Attempts, summary)
+	}
+	w.Flush()
+}
+
+// showWebhookDelivery prints one delivery and its raw payload.
+func showWebhookDelivery(args []string) {
+	if len(args) != 1 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	connect()
+	delivery := findDelivery(args[0])
+
+	encoded, _ := json.MarshalIndent(delivery, "", "  ")
+	fmt.Println(string(encoded))
+
+	var payload bytes.Buffer
+	if err := json.Indent(&payload, []byte(delivery.Payload), "", "  "); err != nil {
+		fmt.Println(delivery.Payload)
+		return
+	}
+	fmt.Println(payload.String())
+}
+
+// replayWebhook applies a logged delivery again, or processes a recorded
+// payload from a file as a new delivery.
+func replayWebhook(args []string) {
+	fs := flag.NewFlagSet("webhook-replay", flag.ExitOnError)
+	event := fs.String("event", "", "event of the recorded payload, e.g. pull_request")
+	file := fs.String("file", "", "recorded payload to process")
+	fs.Parse(args)
+
+	connect()
+
+	var delivery *models.WebhookDelivery
+	var err error
+	switch {
+	case *file != "":
+		if *event == "" {
+			log.Fatal("-event is required with -file")
+		}
+		payload, readErr := os.ReadFile(*file)
+		if readErr != nil {
+			log.Fatal("Failed to read payload:", readErr)
+		}
+		delivery, _, err = services.HandleGitHubWebhook("replay-"+uuid.NewString(), *event, payload)
+	case fs.NArg() == 1:
+		delivery = findDelivery(fs.Arg(0))
+		err = services.ProcessWebhookDelivery(delivery)
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if delivery == nil {
+		log.Fatal("Failed to record delivery:", err)
+	}
+	if err != nil {
+		log.Fatalf("Delivery %s failed: %v", delivery.DeliveryID, err)
+	}
+	log.Printf("Delivery %s %s: %s", delivery.DeliveryID, delivery.Status, delivery.Summary)
+}
+
+func findDelivery(deliveryID string) *models.WebhookDelivery {
+	var delivery models.WebhookDelivery
+	if err := database.DB.Where("delivery_id = ?", deliveryID).First(&delivery).Error; err != nil {
+		log.Fatalf("Delivery %s not found", deliveryID)
+	}
+	return &delivery
+}
+
+func connect() {
+	cfg := config.LoadConfig()
+	if err := database.Connect(cfg); err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+}
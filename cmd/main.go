@@ -6,7 +6,9 @@ import (
 	"github-notes-backend/internal/config"
 	"github-notes-backend/internal/database"
 	"github-notes-backend/internal/handlers"
+	"github-notes-backend/internal/jobs"
 	"github-notes-backend/internal/middleware"
+	"github-notes-backend/internal/services"
 
 	"github.com/gin-gonic/gin"
 )
@@ -26,10 +28,26 @@ func main() {
 	// Add CORS middleware
 	router.Use(middleware.CORSMiddleware())
 
+	// Keep cached PRs fresh in the background
+	prRefresher := services.NewPRRefresher(cfg)
+	jobs.NewPRRefreshJob(cfg, prRefresher).Start()
+
+	// Delete notes that stayed in the trash past the retention period
+	jobs.NewTrashPurgeJob(cfg).Start()
+
+	// Private PRs in the shared cache are only shown to users who can read them
+	accessChecker := services.NewAccessChecker(cfg)
+
 	// Initialize handlers
 	authHandler := handlers.NewAuthHandler(cfg)
-	userHandler := handlers.NewUserHandler()
-	noteHandler := handlers.NewNoteHandler()
+	userHandler := handlers.NewUserHandler(cfg)
+	noteHandler := handlers.NewNoteHandler(cfg, prRefresher, accessChecker)
+	pullRequestHandler := handlers.NewPullRequestHandler(prRefresher, accessChecker)
+	githubHandler := handlers.NewGitHubHandler(noteHandler)
+	repositoryHandler := handlers.NewRepositoryHandler(prRefresher, accessChecker)
+	webhookHandler := handlers.NewWebhookHandler(cfg)
+	tagHandler := handlers.NewTagHandler()
+	notebookHandler := handlers.NewNotebookHandler()
 
 	// Public routes
 	api := router.Group("/api")
@@ -39,6 +57,12 @@ func main() {
 			auth.POST("/register", authHandler.Register)
 			auth.POST("/login", authHandler.Login)
 		}
+
+		// Webhooks are authenticated by their signature
+		webhooks := api.Group("/webhooks")
+		{
+			webhooks.POST("/github", webhookHandler.GitHub)
+		}
 	}
 
 	// Protected routes
@@ -50,6 +74,9 @@ func main() {
 		{
 			user.GET("/profile", userHandler.GetProfile)
 			user.PUT("/profile", userHandler.UpdateProfile)
+			user.GET("/credentials", userHandler.ListCredentials)
+			user.POST("/credentials", userHandler.CreateCredential)
+			user.DELETE("/credentials/:id", userHandler.DeleteCredential)
 		}
 
 		// Note routes
@@ -60,6 +87,73 @@ func main() {
 			notes.GET("/:id", noteHandler.GetNote)
 			notes.PUT("/:id", noteHandler.UpdateNote)
 			notes.DELETE("/:id", noteHandler.DeleteNote)
+			notes.POST("/:id/restore", noteHandler.RestoreNote)
+			notes.POST("/:id/publish", noteHandler.PublishNote)
+			notes.POST("/:id/refresh-links", noteHandler.RefreshNoteLinks)
+			notes.POST("/:id/links", noteHandler.AddNoteLink)
+			notes.DELETE("/:id/links/:kind/:linkId", noteHandler.RemoveNoteLink)
+			notes.POST("/:id/move", noteHandler.MoveNote)
+			notes.GET("/:id/revisions", noteHandler.GetRevisions)
+			notes.GET("/:id/revisions/diff", noteHandler.DiffRevisions)
+			notes.GET("/:id/revisions/:rev", noteHandler.GetRevision)
+			notes.POST("/:id/revisions/:rev/restore", noteHandler.RestoreRevision)
+		}
+
+		// Trash routes
+		trash := protected.Group("/trash")
+		{
+			trash.GET("", noteHandler.ListTrash)
+			trash.DELETE("", noteHandler.EmptyTrash)
+			trash.DELETE("/:id", noteHandler.PurgeNote)
+		}
+
+		// Notebook routes
+		notebooks := protected.Group("/notebooks")
+		{
+			notebooks.GET("", notebookHandler.ListNotebooks)
+			notebooks.POST("", notebookHandler.CreateNotebook)
+			notebooks.GET("/:id", notebookHandler.GetNotebook)
+			notebooks.PUT("/:id", notebookHandler.UpdateNotebook)
+			notebooks.POST("/:id/move", notebookHandler.MoveNotebook)
+			notebooks.DELETE("/:id", notebookHandler.DeleteNotebook)
+		}
+
+		// Tag routes
+		tags := protected.Group("/tags")
+		{
+			tags.GET("", tagHandler.ListTags)
+			tags.POST("", tagHandler.CreateTag)
+			tags.GET("/:id", tagHandler.GetTag)
+			tags.PUT("/:id", tagHandler.UpdateTag)
+			tags.DELETE("/:id", tagHandler.DeleteTag)
+			tags.POST("/:id/merge", tagHandler.MergeTag)
+		}
+
+		// Pull request routes
+		pullRequests := protected.Group("/pull-requests")
+		{
+			pullRequests.GET("", pullRequestHandler.ListPullRequests)
+			pullRequests.GET("/by-ref/:owner/:repo/:number", pullRequestHandler.GetPullRequestByRef)
+			pullRequests.GET("/:id", pullRequestHandler.GetPullRequest)
+			pullRequests.POST("/refresh-all", pullRequestHandler.RefreshAll)
+			pullRequests.POST("/:id/refresh", pullRequestHandler.RefreshPullRequest)
+			pullRequests.GET("/:id/comments", pullRequestHandler.GetComments)
+			pullRequests.GET("/:id/timeline", pullRequestHandler.GetTimeline)
+		}
+
+		// Repository routes
+		repositories := protected.Group("/repositories")
+		{
+			repositories.GET("", repositoryHandler.ListRepositories)
+			repositories.POST("/sync", repositoryHandler.SyncRepositories)
+			repositories.GET("/:id/notes", repositoryHandler.GetRepositoryNotes)
+		}
+
+		// GitHub inbox routes
+		github := protected.Group("/github")
+		{
+			github.GET("/pull-requests", githubHandler.ListPullRequests)
+			github.POST("/pull-requests/:owner/:repo/:number/note", githubHandler.CreateNoteFromPR)
 		}
 	}
 
@@ -82,21 +82,21 @@ const NoteCard = ({ note, onDelete }) => {
             {note.content}
           </p>
           
-          {note.github_pr_number && (
-            <div className="mb-2">
-              <span className={`badge badge-pr bg-${getPRStateBadge(note.pr_state)} me-2`}>
-                PR #{note.github_pr_number}
+          {(note.pull_requests || []).map((pr) => (
+            <div key={pr.id} className="mb-2">
+              <span className={`badge badge-pr bg-${getPRStateBadge(pr.state)} me-2`}>
+                PR #{pr.number}
               </span>
-              {note.pr_state && (
-                <span className={`badge bg-light text-dark ${getPRStateClass(note.pr_state)}`}>
-                  {note.pr_state.toUpperCase()}
+              {pr.state && (
+                <span className={`badge bg-light text-dark ${getPRStateClass(pr.state)}`}>
+                  {pr.state.toUpperCase()}
                 </span>
               )}
               <div className="small text-muted mt-1">
-                {note.repo_owner}/{note.repo_name}
+                {pr.repo_owner}/{pr.repo_name}
               </div>
             </div>
-          )}
+          ))}
           
           <div className="mt-auto">
             <small className="text-muted">
@@ -26,7 +26,7 @@ const Dashboard = () => {
       
       // Calculate stats
       const totalNotes = response.pagination?.total || notes.length;
-      const notesWithPR = notes.filter(note => note.github_pr_number).length;
+      const notesWithPR = notes.filter(note => note.pull_requests?.length).length;
       
       setStats({
         totalNotes,
@@ -242,11 +242,11 @@ const Dashboard = () => {
                               <small className="text-muted">
                                 {formatDate(note.created_at)}
                               </small>
-                              {note.github_pr_number && (
-                                <span className={`badge bg-${getPRStateBadge(note.pr_state)} small`}>
-                                  PR #{note.github_pr_number}
+                              {(note.pull_requests || []).map((pr) => (
+                                <span key={pr.id} className={`badge bg-${getPRStateBadge(pr.state)} small`}>
+                                  PR #{pr.number}
                                 </span>
-                              )}
+                              ))}
                             </div>
                           </div>
                           <Link 
@@ -4,6 +4,9 @@ import { toast } from 'react-toastify';
 import { noteService } from '../services/noteService';
 import LoadingSpinner from '../components/LoadingSpinner';
 
+// The form edits the note's first linked PR
+const linkedPR = (note) => note?.pull_requests?.[0];
+
 const EditNote = () => {
   const { id } = useParams();
   const navigate = useNavigate();
@@ -30,9 +33,9 @@ const EditNote = () => {
       setFormData({
         title: noteData.title || '',
         content: noteData.content || '',
-        github_pr_number: noteData.github_pr_number || '',
-        repo_owner: noteData.repo_owner || '',
-        repo_name: noteData.repo_name || ''
+        github_pr_number: linkedPR(noteData)?.number || '',
+        repo_owner: linkedPR(noteData)?.repo_owner || '',
+        repo_name: linkedPR(noteData)?.repo_name || ''
       });
     } catch (error) {
       toast.error('Failed to fetch note');
@@ -72,8 +75,14 @@ const EditNote = () => {
         content: formData.content.trim()
       };
 
-      // Add GitHub PR data if provided (and different from original)
-      if (formData.github_pr_number) {
+      // Add GitHub PR data if provided and different from original; sending
+      // it replaces every PR linked to the note
+      const original = linkedPR(note);
+      const changed = !original ||
+        parseInt(formData.github_pr_number) !== original.number ||
+        formData.repo_owner.trim().toLowerCase() !== original.repo_owner ||
+        formData.repo_name.trim().toLowerCase() !== original.repo_name;
+      if (formData.github_pr_number && changed) {
         noteData.github_pr_number = parseInt(formData.github_pr_number);
         noteData.repo_owner = formData.repo_owner.trim();
         noteData.repo_name = formData.repo_name.trim();
@@ -234,14 +243,14 @@ const EditNote = () => {
                       GitHub PR Integration
                     </h5>
                     
-                    {note.github_pr_number ? (
+                    {linkedPR(note) ? (
                       <div className="alert alert-info" role="alert">
                         <h6 className="alert-heading">
                           <i className="fab fa-github me-2"></i>
                           Current PR Information
                         </h6>
                         <p className="mb-2">
-                          This note is linked to PR #{note.github_pr_number} in {note.repo_owner}/{note.repo_name}
+                          This note is linked to {note.pull_requests.map((pr) => `${pr.repo_owner}/${pr.repo_name}#${pr.number}`).join(', ')}
                         </p>
                         <small className="text-muted">
                           <i className="fas fa-info-circle me-1"></i>
@@ -167,8 +167,8 @@ const NoteDetail = () => {
               </div>
 
               {/* GitHub PR Information */}
-              {note.github_pr_number && (
-                <div className="github-pr-info">
+              {(note.pull_requests || []).map((pr) => (
+                <div key={pr.id} className="github-pr-info">
                   <h5 className="mb-3">
                     <i className="fab fa-github me-2"></i>
                     GitHub Pull Request Information
@@ -178,36 +178,36 @@ const NoteDetail = () => {
                       <div className="mb-2">
                         <strong>Repository:</strong>{' '}
                         <a 
-                          href={`https://github.com/${note.repo_owner}/${note.repo_name}`}
+                          href={`https://github.com/${pr.repo_owner}/${pr.repo_name}`}
                           target="_blank"
                           rel="noopener noreferrer"
                           className="text-decoration-none"
                         >
-                          {note.repo_owner}/{note.repo_name}
+                          {pr.repo_owner}/{pr.repo_name}
                           <i className="fas fa-external-link-alt ms-1 small"></i>
                         </a>
                       </div>
                       <div className="mb-2">
                         <strong>PR Number:</strong>{' '}
-                        <span className={`badge bg-${getPRStateBadge(note.pr_state)}`}>
-                          #{note.github_pr_number}
+                        <span className={`badge bg-${getPRStateBadge(pr.state)}`}>
+                          #{pr.number}
                         </span>
                       </div>
-                      {note.pr_state && (
+                      {pr.state && (
                         <div className="mb-2">
                           <strong>Status:</strong>{' '}
-                          <span className={`fw-bold ${getPRStateClass(note.pr_state)}`}>
-                            {note.pr_state.toUpperCase()}
+                          <span className={`fw-bold ${getPRStateClass(pr.state)}`}>
+                            {pr.state.toUpperCase()}
                           </span>
                         </div>
                       )}
                     </div>
-                    {note.pr_url && (
+                    {pr.url && (
                       <div className="col-md-6">
                         <div className="mb-2">
                           <strong>PR Link:</strong>{' '}
                           <a 
-                            href={note.pr_url}
+                            href={pr.url}
                             target="_blank"
                             rel="noopener noreferrer"
                             className="btn btn-sm btn-outline-primary"
@@ -221,38 +221,38 @@ const NoteDetail = () => {
                     )}
                   </div>
                   
-                  {note.pr_title && (
+                  {pr.title && (
                     <div className="mt-3">
                       <strong>PR Title:</strong>
                       <div className="bg-white p-2 rounded border mt-1">
-                        {note.pr_title}
+                        {pr.title}
                       </div>
                     </div>
                   )}
                   
-                  {note.pr_author && (
+                  {pr.author && (
                     <div className="mt-2">
                       <strong>Author:</strong>{' '}
                       <a 
-                        href={`https://github.com/${note.pr_author}`}
+                        href={`https://github.com/${pr.author}`}
                         target="_blank"
                         rel="noopener noreferrer"
                         className="text-decoration-none"
                       >
-                        @{note.pr_author}
+                        @{pr.author}
                         <i className="fas fa-external-link-alt ms-1 small"></i>
                       </a>
                     </div>
                   )}
                   
-                  {note.pr_created_at && (
+                  {pr.created_at && (
                     <div className="mt-2">
                       <strong>PR Created:</strong>{' '}
-                      {formatDate(note.pr_created_at)}
+                      {formatDate(pr.created_at)}
                     </div>
                   )}
                 </div>
-              )}
+              ))}
 
               {/* Note Content */}
               <div className="card-body">
@@ -3,6 +3,9 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -16,6 +19,45 @@ type Config struct {
 	DBSSLMode  string
 	JWTSecret  string
 	Port       string
+
+	GithubGraphQLURL       string
+	GithubGraphQLBatchSize int
+
+	// How long a cached PR is trusted before it is refetched, per PR state
+	PRCacheTTLOpen   time.Duration
+	PRCacheTTLClosed time.Duration
+	PRCacheTTLMerged time.Duration
+
+	// Background refresh of stale PRs; an interval of 0 disables it
+	PRRefreshInterval  time.Duration
+	PRRefreshBatchSize int
+
+	// Minimum time between manual refreshes by the same user
+	PRManualRefreshCooldown time.Duration
+
+	// Secret shared with GitHub to sign webhook deliveries
+	GithubWebhookSecret string
+
+	// How long a user's verified access, or lack of it, to a private
+	// repository is cached
+	RepoAccessTTL       time.Duration
+	RepoAccessDeniedTTL time.Duration
+
+	// Note revisions are kept while they are among the last
+	// NoteRevisionKeepLast of a note or younger than NoteRevisionKeepDays.
+	// 0 turns a rule off; with both off every revision is kept.
+	NoteRevisionKeepLast int
+	NoteRevisionKeepDays int
+
+	// Trashed notes are deleted for good after TrashRetention, checked every
+	// TrashPurgeInterval; either set to 0 keeps them until emptied by hand
+	TrashRetention     time.Duration
+	TrashPurgeInterval time.Duration
+
+	// Hosts credentials may point at although they are served over plain
+	// http or resolve to loopback, private or link-local addresses, e.g. a
+	// GitLab on the internal network
+	CodeHostAllowedHosts []string
 }
 
 func LoadConfig() *Config {
@@ -34,6 +76,31 @@ func LoadConfig() *Config {
 		DBSSLMode:  getEnv("DB_SSL_MODE", "disable"),
 		JWTSecret:  getEnv("JWT_SECRET", "your_super_secret_jwt_key"),
 		Port:       getEnv("PORT", "8080"),
+
+		GithubGraphQLURL:       getEnv("GITHUB_GRAPHQL_URL", "https://api.github.com/graphql"),
+		GithubGraphQLBatchSize: getEnvInt("GITHUB_GRAPHQL_BATCH_SIZE", 50),
+
+		PRCacheTTLOpen:   getEnvDuration("PR_CACHE_TTL_OPEN", 15*time.Minute),
+		PRCacheTTLClosed: getEnvDuration("PR_CACHE_TTL_CLOSED", 24*time.Hour),
+		PRCacheTTLMerged: getEnvDuration("PR_CACHE_TTL_MERGED", 7*24*time.Hour),
+
+		PRRefreshInterval:  getEnvDuration("PR_REFRESH_INTERVAL", 10*time.Minute),
+		PRRefreshBatchSize: getEnvInt("PR_REFRESH_BATCH_SIZE", 200),
+
+		PRManualRefreshCooldown: getEnvDuration("PR_MANUAL_REFRESH_COOLDOWN", 30*time.Second),
+
+		GithubWebhookSecret: getEnv("GITHUB_WEBHOOK_SECRET", ""),
+
+		RepoAccessTTL:       getEnvDuration("REPO_ACCESS_TTL", time.Hour),
+		RepoAccessDeniedTTL: getEnvDuration("REPO_ACCESS_DENIED_TTL", 10*time.Minute),
+
+		NoteRevisionKeepLast: getEnvInt("NOTE_REVISION_KEEP_LAST", 50),
+		NoteRevisionKeepDays: getEnvInt("NOTE_REVISION_KEEP_DAYS", 30),
+
+		TrashRetention:     getEnvDuration("TRASH_RETENTION", 30*24*time.Hour),
+		TrashPurgeInterval: getEnvDuration("TRASH_PURGE_INTERVAL", time.Hour),
+
+		CodeHostAllowedHosts: getEnvList("CODE_HOST_ALLOWED_HOSTS"),
 	}
 
 	return config
@@ -45,3 +112,35 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil {
+			return parsed
+		}
+		log.Printf("Invalid value for %s, using default %d", key, defaultValue)
+	}
+	return defaultValue
+}
+
+// getEnvList reads a comma separated list, dropping empty entries.
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// getEnvDuration reads a duration such as "15m" or "24h".
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil {
+			return parsed
+		}
+		log.Printf("Invalid value for %s, using default %s", key, defaultValue)
+	}
+	return defaultValue
+}
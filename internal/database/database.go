@@ -17,7 +17,8 @@ func Connect(cfg *config.Config) error {
 	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=%s TimeZone=UTC",
 		cfg.DBHost, cfg.DBUser, cfg.DBPassword, cfg.DBName, cfg.DBPort, cfg.DBSSLMode)
 
-	database, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	// TranslateError reports unique violations as gorm.ErrDuplicatedKey
+	database, err := gorm.Open(postgres.Open(dsn), &gorm.Config{TranslateError: true})
 	if err != nil {
 		return fmt.Errorf("failed to connect to database: %w", err)
 	}
@@ -25,11 +26,20 @@ func Connect(cfg *config.Config) error {
 	DB = database
 
 	// Auto Migrate the schema
-	err = DB.AutoMigrate(&models.User{}, &models.Note{}, &models.PullRequest{}, &models.NotePRLink{})
+	err = DB.AutoMigrate(&models.User{}, &models.Note{}, &models.PullRequest{}, &models.NotePRLink{},
+		&models.Issue{}, &models.NoteIssueLink{}, &models.Commit{}, &models.NoteCommitLink{}, &models.PRComment{},
+		&models.Credential{}, &models.Repository{}, &models.WebhookDelivery{},
+		&models.RepositoryAccess{}, &models.UserRepository{}, &models.PullRequestEvent{},
+		&models.NotePRSnapshot{}, &models.Tag{}, &models.NoteTag{},
+		&models.Notebook{}, &models.NoteRevision{})
 	if err != nil {
 		return fmt.Errorf("failed to migrate database: %w", err)
 	}
 
+	if err := runMigrations(DB); err != nil {
+		return fmt.Errorf("failed to migrate database: %w", err)
+	}
+
 	log.Println("Database connected and migrated successfully")
 	return nil
 }
@@ -0,0 +1,345 @@
+package database
+
+import (
+	"fmt"
+	"log"
+
+	"github-notes-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// migration is a one-off data migration that AutoMigrate cannot express.
+// Each migration runs once, in order, inside its own transaction.
+type migration struct {
+	ID  string
+	Run func(tx *gorm.DB) error
+}
+
+var migrations = []migration{
+	{
+		// Register every repository already referenced by notes or PRs and
+		// point those rows at the registry.
+		ID: "0001_backfill_repositories",
+		Run: func(tx *gorm.DB) error {
+			refs := `SELECT provider, host, repo_owner, repo_name FROM pull_requests`
+			legacyNotes := hasLegacyNoteColumns(tx)
+			if legacyNotes {
+				refs += `
+					UNION
+					SELECT 'github', 'github.com', repo_owner, repo_name FROM notes
+					WHERE repo_owner <> '' AND repo_name <> ''`
+			}
+
+			statements := []string{
+				`INSERT INTO repositories (id, provider, host, owner, name, created_at, updated_at)
+				 SELECT gen_random_uuid(), provider, host, repo_owner, repo_name, NOW(), NOW()
+				 FROM (` + refs + `) refs
+				 ON CONFLICT (provider, host, owner, name) DO NOTHING`,
+				`UPDATE pull_requests SET repository_id = repositories.id
+				 FROM repositories
+				 WHERE pull_requests.repository_id IS NULL
+				   AND repositories.provider = pull_requests.provider
+				   AND repositories.host = pull_requests.host
+				   AND repositories.owner = pull_requests.repo_owner
+				   AND repositories.name = pull_requests.repo_name`,
+			}
+			if legacyNotes {
+				statements = append(statements,
+					`UPDATE notes SET repository_id = repositories.id
+					 FROM repositories
+					 WHERE notes.repository_id IS NULL
+					   AND repositories.provider = 'github'
+					   AND repositories.host = 'github.com'
+					   AND repositories.owner = notes.repo_owner
+					   AND repositories.name = notes.repo_name`,
+				)
+			}
+			for _, statement := range statements {
+				if err := tx.Exec(statement).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		// Lowercase repository identifiers, fold PRs that were cached more than
+		// once into a single row and enforce the cache key. note_pr_links is
+		// repaired first: it used to be written through a pull_request_id
+		// column while every query read pr_id.
+		ID: "0002_pull_request_cache_key",
+		Run: func(tx *gorm.DB) error {
+			var statements []string
+			if tx.Migrator().HasColumn("note_pr_links", "pull_request_id") {
+				statements = append(statements,
+					`UPDATE note_pr_links SET pr_id = pull_request_id WHERE pr_id IS NULL`,
+					`ALTER TABLE note_pr_links DROP CONSTRAINT IF EXISTS note_pr_links_pkey`,
+					`ALTER TABLE note_pr_links DROP COLUMN pull_request_id`,
+					`DELETE FROM note_pr_links WHERE pr_id IS NULL`,
+					`DELETE FROM note_pr_links a USING note_pr_links b
+					 WHERE a.ctid < b.ctid AND a.note_id = b.note_id AND a.pr_id = b.pr_id`,
+					`ALTER TABLE note_pr_links ADD PRIMARY KEY (note_id, pr_id)`,
+				)
+			}
+
+			statements = append(statements,
+				`UPDATE pull_requests SET provider = LOWER(TRIM(provider)), host = LOWER(TRIM(host)),
+				 repo_owner = LOWER(TRIM(repo_owner)), repo_name = LOWER(TRIM(repo_name))`,
+				`UPDATE issues SET repo_owner = LOWER(TRIM(repo_owner)), repo_name = LOWER(TRIM(repo_name))`,
+				`UPDATE commits SET repo_owner = LOWER(TRIM(repo_owner)), repo_name = LOWER(TRIM(repo_name))`,
+			)
+			if hasLegacyNoteColumns(tx) {
+				statements = append(statements,
+					`UPDATE notes SET repo_owner = LOWER(TRIM(repo_owner)), repo_name = LOWER(TRIM(repo_name))`,
+				)
+			}
+
+			statements = append(statements,
+				// Keep the most recently updated row of each key
+				`CREATE TEMP TABLE pr_merge ON COMMIT DROP AS
+				 SELECT id AS from_id, FIRST_VALUE(id) OVER (
+					PARTITION BY provider, host, repo_owner, repo_name, number
+					ORDER BY updated_at DESC, created_at ASC
+				 ) AS into_id
+				 FROM pull_requests`,
+				`DELETE FROM pr_merge WHERE from_id = into_id`,
+				`UPDATE note_pr_links SET detected = FALSE
+				 FROM note_pr_links manual JOIN pr_merge ON pr_merge.from_id = manual.pr_id
+				 WHERE NOT manual.detected
+				   AND note_pr_links.note_id = manual.note_id AND note_pr_links.pr_id = pr_merge.into_id`,
+				`INSERT INTO note_pr_links (note_id, pr_id, detected)
+				 SELECT note_pr_links.note_id, pr_merge.into_id, BOOL_AND(note_pr_links.detected)
+				 FROM note_pr_links JOIN pr_merge ON pr_merge.from_id = note_pr_links.pr_id
+				 GROUP BY note_pr_links.note_id, pr_merge.into_id
+				 ON CONFLICT DO NOTHING`,
+				`DELETE FROM note_pr_links USING pr_merge WHERE note_pr_links.pr_id = pr_merge.from_id`,
+				`UPDATE pr_comments SET pull_request_id = pr_merge.into_id
+				 FROM pr_merge WHERE pr_comments.pull_request_id = pr_merge.from_id`,
+				`UPDATE notes SET published_pr_id = pr_merge.into_id
+				 FROM pr_merge WHERE notes.published_pr_id = pr_merge.from_id`,
+				`DELETE FROM pull_requests USING pr_merge WHERE pull_requests.id = pr_merge.from_id`,
+
+				`CREATE UNIQUE INDEX IF NOT EXISTS idx_pull_requests_key
+				 ON pull_requests (provider, host, repo_owner, repo_name, number)`,
+			)
+
+			for _, statement := range statements {
+				if err := tx.Exec(statement).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		// PRs cached before repository visibility was tracked are treated as
+		// private until their next refresh records the real visibility.
+		ID: "0003_private_pull_requests",
+		Run: func(tx *gorm.DB) error {
+			return tx.Exec(`UPDATE pull_requests SET private = TRUE`).Error
+		},
+	},
+	{
+		// Notes used to link a single PR through github_pr_number, repo_owner
+		// and repo_name. Links now live only in note_pr_links, so carry over
+		// any legacy link that is missing there before dropping the columns.
+		ID: "0004_drop_note_legacy_pr_columns",
+		Run: func(tx *gorm.DB) error {
+			if !hasLegacyNoteColumns(tx) {
+				return nil
+			}
+
+			statements := []string{
+				`UPDATE notes SET repository_id = repositories.id
+				 FROM repositories
+				 WHERE notes.repository_id IS NULL
+				   AND repositories.provider = 'github'
+				   AND repositories.host = 'github.com'
+				   AND repositories.owner = LOWER(notes.repo_owner)
+				   AND repositories.name = LOWER(notes.repo_name)`,
+				`INSERT INTO note_pr_links (note_id, pr_id)
+				 SELECT notes.id, pull_requests.id
+				 FROM notes JOIN pull_requests
+				   ON pull_requests.provider = 'github'
+				  AND pull_requests.host = 'github.com'
+				  AND pull_requests.repo_owner = LOWER(notes.repo_owner)
+				  AND pull_requests.repo_name = LOWER(notes.repo_name)
+				  AND pull_requests.number = notes.github_pr_number
+				 ON CONFLICT DO NOTHING`,
+				`ALTER TABLE notes DROP COLUMN IF EXISTS github_pr_number`,
+				`ALTER TABLE notes DROP COLUMN IF EXISTS repo_owner`,
+				`ALTER TABLE notes DROP COLUMN IF EXISTS repo_name`,
+			}
+			for _, statement := range statements {
+				if err := tx.Exec(statement).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		// Tag names are unique per user regardless of case, which a gorm
+		// index tag cannot express.
+		ID: "0005_tag_name_index",
+		Run: func(tx *gorm.DB) error {
+			return tx.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_tags_user_name ON tags (user_id, LOWER(name))`).Error
+		},
+	},
+	{
+		// Give every existing note a first revision holding its current
+		// state, so the edit that follows can be diffed and undone.
+		ID: "0006_backfill_note_revisions",
+		Run: func(tx *gorm.DB) error {
+			return tx.Exec(`INSERT INTO note_revisions (id, note_id, number, title, content, links, editor_id, created_at)
+				SELECT gen_random_uuid(), notes.id, 1, notes.title, notes.content,
+					COALESCE((SELECT jsonb_agg(jsonb_build_object(
+						'kind', 'pull_request', 'id', pull_requests.id,
+						'ref', pull_requests.repo_owner || '/' || pull_requests.repo_name || '#' || pull_requests.number,
+						'url', pull_requests.url, 'detected', note_pr_links.detected))
+					 FROM note_pr_links JOIN pull_requests ON pull_requests.id = note_pr_links.pr_id
+					 WHERE note_pr_links.note_id = notes.id), '[]'::jsonb)
+					|| COALESCE((SELECT jsonb_agg(jsonb_build_object(
+						'kind', 'issue', 'id', issues.id,
+						'ref', issues.repo_owner || '/' || issues.repo_name || '#' || issues.number,
+						'url', issues.url, 'detected', note_issue_links.detected))
+					 FROM note_issue_links JOIN issues ON issues.id = note_issue_links.issue_id
+					 WHERE note_issue_links.note_id = notes.id), '[]'::jsonb)
+					|| COALESCE((SELECT jsonb_agg(jsonb_build_object(
+						'kind', 'commit', 'id', commits.id,
+						'ref', commits.repo_owner || '/' || commits.repo_name || '@' || LEFT(commits.sha, 7),
+						'url', commits.url))
+					 FROM note_commit_links JOIN commits ON commits.id = note_commit_links.commit_id
+					 WHERE note_commit_links.note_id = notes.id), '[]'::jsonb),
+					notes.user_id, notes.updated_at
+				FROM notes
+				WHERE NOT EXISTS (SELECT 1 FROM note_revisions WHERE note_revisions.note_id = notes.id)`).Error
+		},
+	},
+	{
+		// Merged PRs used to be cached as closed; mark the ones cached before
+		// the merged state existed so pr_state=merged finds them.
+		ID: "0007_backfill_merged_pull_requests",
+		Run: func(tx *gorm.DB) error {
+			return tx.Exec(`UPDATE pull_requests SET state = 'merged' WHERE state = 'closed' AND merged_at IS NOT NULL`).Error
+		},
+	},
+	{
+		// Issues and commits cached before their visibility was tracked are
+		// treated as private, so they are only shown to users who can read
+		// them, like the PRs in 0003.
+		ID: "0008_private_issues_and_commits",
+		Run: func(tx *gorm.DB) error {
+			if err := tx.Exec(`UPDATE issues SET private = TRUE`).Error; err != nil {
+				return err
+			}
+			return tx.Exec(`UPDATE commits SET private = TRUE`).Error
+		},
+	},
+	{
+		// Fold issues that were cached more than once into a single row and
+		// enforce the cache key, as 0002 did for PRs. A note linked to
+		// several copies keeps a manual link if any of them was one.
+		ID: "0009_issue_cache_key",
+		Run: func(tx *gorm.DB) error {
+			statements := []string{
+				`CREATE TEMP TABLE issue_merge ON COMMIT DROP AS
+				 SELECT id AS from_id, FIRST_VALUE(id) OVER (
+					PARTITION BY host, repo_owner, repo_name, number
+					ORDER BY updated_at DESC, created_at ASC
+				 ) AS into_id
+				 FROM issues`,
+				`DELETE FROM issue_merge WHERE from_id = into_id`,
+				`UPDATE note_issue_links SET detected = FALSE
+				 FROM note_issue_links manual JOIN issue_merge ON issue_merge.from_id = manual.issue_id
+				 WHERE NOT manual.detected
+				   AND note_issue_links.note_id = manual.note_id AND note_issue_links.issue_id = issue_merge.into_id`,
+				`INSERT INTO note_issue_links (note_id, issue_id, detected)
+				 SELECT note_issue_links.note_id, issue_merge.into_id, BOOL_AND(note_issue_links.detected)
+				 FROM note_issue_links JOIN issue_merge ON issue_merge.from_id = note_issue_links.issue_id
+				 GROUP BY note_issue_links.note_id, issue_merge.into_id
+				 ON CONFLICT DO NOTHING`,
+				`DELETE FROM note_issue_links USING issue_merge WHERE note_issue_links.issue_id = issue_merge.from_id`,
+				`DELETE FROM issues USING issue_merge WHERE issues.id = issue_merge.from_id`,
+
+				`CREATE UNIQUE INDEX IF NOT EXISTS idx_issues_key
+				 ON issues (host, repo_owner, repo_name, number)`,
+			}
+			for _, statement := range statements {
+				if err := tx.Exec(statement).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		// Fold commits that were cached more than once into a single row
+		// and enforce the cache key, as 0009 did for issues.
+		ID: "0010_commit_cache_key",
+		Run: func(tx *gorm.DB) error {
+			statements := []string{
+				`UPDATE commits SET sha = LOWER(TRIM(sha))`,
+				`CREATE TEMP TABLE commit_merge ON COMMIT DROP AS
+				 SELECT id AS from_id, FIRST_VALUE(id) OVER (
+					PARTITION BY repo_owner, repo_name, sha
+					ORDER BY updated_at DESC, created_at ASC
+				 ) AS into_id
+				 FROM commits`,
+				`DELETE FROM commit_merge WHERE from_id = into_id`,
+				`INSERT INTO note_commit_links (note_id, commit_id)
+				 SELECT DISTINCT note_commit_links.note_id, commit_merge.into_id
+				 FROM note_commit_links JOIN commit_merge ON commit_merge.from_id = note_commit_links.commit_id
+				 ON CONFLICT DO NOTHING`,
+				`DELETE FROM note_commit_links USING commit_merge WHERE note_commit_links.commit_id = commit_merge.from_id`,
+				`DELETE FROM commits USING commit_merge WHERE commits.id = commit_merge.from_id`,
+
+				`CREATE UNIQUE INDEX IF NOT EXISTS idx_commits_key
+				 ON commits (repo_owner, repo_name, sha)`,
+			}
+			for _, statement := range statements {
+				if err := tx.Exec(statement).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+}
+
+// hasLegacyNoteColumns reports whether the notes table still has the single
+// PR columns dropped by 0004. Databases created after that never had them.
+func hasLegacyNoteColumns(tx *gorm.DB) bool {
+	return tx.Migrator().HasColumn("notes", "repo_owner")
+}
+
+// runMigrations applies every migration that has not been recorded yet.
+func runMigrations(db *gorm.DB) error {
+	if err := db.AutoMigrate(&models.SchemaMigration{}); err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		var count int64
+		if err := db.Model(&models.SchemaMigration{}).Where("id = ?", m.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := m.Run(tx); err != nil {
+				return err
+			}
+			return tx.Create(&models.SchemaMigration{ID: m.ID}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("migration %s failed: %w", m.ID, err)
+		}
+		log.Printf("Applied migration %s", m.ID)
+	}
+
+	return nil
+}
@@ -0,0 +1,298 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github-notes-backend/internal/database"
+	"github-notes-backend/internal/middleware"
+	"github-notes-backend/internal/models"
+	"github-notes-backend/internal/services"
+	"github-notes-backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type GitHubHandler struct {
+	githubService *services.GitHubService
+	noteHandler   *NoteHandler
+}
+
+// inboxSearches maps each inbox relation to its GitHub search qualifier.
+var inboxSearches = []struct {
+	relation  string
+	qualifier string
+}{
+	{models.InboxAuthored, "author:@me"},
+	{models.InboxReviewRequested, "review-requested:@me"},
+	{models.InboxAssigned, "assignee:@me"},
+}
+
+// maxInboxResults caps how many PRs are fetched per relation. Each page of
+// 100 costs one request of GitHub's search quota of 30 a minute.
+const maxInboxResults = 300
+
+var inboxStates = map[string]string{
+	"open":   "is:open",
+	"closed": "is:closed",
+	"merged": "is:merged",
+	"all":    "",
+}
+
+func NewGitHubHandler(noteHandler *NoteHandler) *GitHubHandler {
+	return &GitHubHandler{
+		githubService: services.NewGitHubService(),
+		noteHandler:   noteHandler,
+	}
+}
+
+// ListPullRequests lists PRs the caller authored, was asked to review or is
+// assigned to, newest first. Filter with relation= and state= (open by default).
+// Only the newest maxInboxResults PRs of each relation are listed; incomplete
+// tells the caller when GitHub matched more.
+func (h *GitHubHandler) ListPullRequests(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "User not found")
+		return
+	}
+
+	if user.GithubToken == "" {
+		utils.ErrorResponse(c, http.StatusBadRequest, "GitHub token is required to list pull requests. Please update your profile first.")
+		return
+	}
+
+	relation := c.Query("relation")
+	stateQualifier, ok := inboxStates[c.DefaultQuery("state", "open")]
+	if !ok {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid state, expected open, closed, merged or all")
+		return
+	}
+
+	page, limit, offset := utils.GetPaginationParams(c)
+
+	byKey := make(map[string]*models.InboxPullRequest)
+	totalCounts := make(map[string]int)
+	incomplete := false
+	matchedRelation := false
+	for _, search := range inboxSearches {
+		if relation != "" && relation != search.relation {
+			continue
+		}
+		matchedRelation = true
+
+		result, err := h.githubService.SearchPullRequests(search.qualifier+" "+stateQualifier, user.GithubToken, maxInboxResults)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadGateway, "Failed to search GitHub: "+err.Error())
+			return
+		}
+		totalCounts[search.relation] = result.TotalCount
+		if result.Incomplete || len(result.Items) < result.TotalCount {
+			incomplete = true
+		}
+
+		for _, item := range result.Items {
+			owner, repo := services.RepoFromURL(item.RepositoryURL)
+			key := fmt.Sprintf("%s/%s#%d", owner, repo, item.Number)
+			if existing, ok := byKey[key]; ok {
+				existing.Relations = append(existing.Relations, search.relation)
+				continue
+			}
+			byKey[key] = inboxItemFromSearch(owner, repo, &item, search.relation)
+		}
+	}
+
+	if !matchedRelation {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid relation, expected authored, review_requested or assigned")
+		return
+	}
+
+	items := make([]models.InboxPullRequest, 0, len(byKey))
+	for _, item := range byKey {
+		items = append(items, *item)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].UpdatedAt.After(items[j].UpdatedAt)
+	})
+
+	total := len(items)
+	if offset > total {
+		offset = total
+	}
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+	items = items[offset:end]
+
+	if err := annotateInboxNotes(items, userID); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to count notes")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, models.InboxResponse{
+		PullRequests: items,
+		Total:        total,
+		Page:         page,
+		Limit:        limit,
+		TotalCounts:  totalCounts,
+		Incomplete:   incomplete,
+	})
+}
+
+// CreateNoteFromPR creates a note linked to the PR, prefilled from its title
+// and URL unless the request overrides them.
+func (h *GitHubHandler) CreateNoteFromPR(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	number, err := strconv.Atoi(c.Param("number"))
+	if err != nil || number <= 0 {
+		utils.ErrorResponse(c, http.StatusBadRequest, "PR number must be greater than 0")
+		return
+	}
+
+	var req models.CreateNoteFromPRRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "User not found")
+		return
+	}
+
+	ref := &models.Reference{
+		Kind:      models.RefPullRequest,
+		Provider:  models.ProviderGitHub,
+		RepoOwner: c.Param("owner"),
+		RepoName:  c.Param("repo"),
+		Number:    number,
+	}
+
+	// Resolve up front so the prefilled title can use the PR title
+	resolved, err := h.noteHandler.resolveReference(ref, &user)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	pr := resolved.PullRequest
+
+	createReq := models.CreateNoteRequest{
+		Title:     req.Title,
+		Content:   req.Content,
+		NoteLinks: models.NoteLinks{GithubRef: ref},
+	}
+	if createReq.Title == "" {
+		createReq.Title = fmt.Sprintf("%s/%s#%d: %s", pr.RepoOwner, pr.RepoName, pr.Number, pr.Title)
+		if runes := []rune(createReq.Title); len(runes) > 255 {
+			createReq.Title = string(runes[:255])
+		}
+	}
+	if createReq.Content == "" {
+		createReq.Content = pr.URL + "\n\n"
+	}
+
+	note, err := h.noteHandler.createNote(&user, &createReq)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, note)
+}
+
+func inboxItemFromSearch(owner, repo string, item *models.GithubSearchIssue, relation string) *models.InboxPullRequest {
+	state := item.State
+	if item.PullRequest != nil && item.PullRequest.MergedAt != nil {
+		state = "merged"
+	}
+
+	return &models.InboxPullRequest{
+		RepoOwner: owner,
+		RepoName:  repo,
+		Number:    item.Number,
+		Title:     item.Title,
+		State:     state,
+		Draft:     item.Draft,
+		Author:    item.User.Login,
+		URL:       item.HTMLURL,
+		UpdatedAt: item.UpdatedAt,
+		Relations: []string{relation},
+	}
+}
+
+// annotateInboxNotes fills in the cached PR id and the caller's note count for
+// each inbox item.
+func annotateInboxNotes(items []models.InboxPullRequest, userID uuid.UUID) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	keys := make([][]interface{}, 0, len(items))
+	for _, item := range items {
+		keys = append(keys, []interface{}{models.NormalizeIdentifier(item.RepoOwner), models.NormalizeIdentifier(item.RepoName), item.Number})
+	}
+
+	var cached []models.PullRequest
+	if err := database.DB.Where("provider = ? AND host = ?", models.ProviderGitHub, models.DefaultGitHubHost).
+		Where("(repo_owner, repo_name, number) IN ?", keys).
+		Find(&cached).Error; err != nil {
+		return err
+	}
+	if len(cached) == 0 {
+		return nil
+	}
+
+	prIDs := make([]uuid.UUID, 0, len(cached))
+	idByKey := make(map[string]uuid.UUID, len(cached))
+	for _, pr := range cached {
+		prIDs = append(prIDs, pr.ID)
+		idByKey[fmt.Sprintf("%s/%s#%d", pr.RepoOwner, pr.RepoName, pr.Number)] = pr.ID
+	}
+
+	var counts []struct {
+		PRID  uuid.UUID
+		Count int64
+	}
+	if err := database.DB.Table("note_pr_links").
+		Select("note_pr_links.pr_id AS pr_id, COUNT(*) AS count").
+		Joins("JOIN notes ON notes.id = note_pr_links.note_id").
+		Where("notes.user_id = ? AND notes.deleted_at IS NULL AND note_pr_links.pr_id IN ?", userID, prIDs).
+		Group("note_pr_links.pr_id").
+		Scan(&counts).Error; err != nil {
+		return err
+	}
+
+	countByID := make(map[uuid.UUID]int64, len(counts))
+	for _, count := range counts {
+		countByID[count.PRID] = count.Count
+	}
+
+	for i := range items {
+		key := fmt.Sprintf("%s/%s#%d", models.NormalizeIdentifier(items[i].RepoOwner), models.NormalizeIdentifier(items[i].RepoName), items[i].Number)
+		if id, ok := idByKey[key]; ok {
+			prID := id
+			items[i].PullRequestID = &prID
+			items[i].NoteCount = countByID[id]
+			items[i].HasNotes = items[i].NoteCount > 0
+		}
+	}
+	return nil
+}
@@ -3,7 +3,10 @@ package handlers
 import (
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
+	"github-notes-backend/internal/config"
 	"github-notes-backend/internal/database"
 	"github-notes-backend/internal/middleware"
 	"github-notes-backend/internal/models"
@@ -12,15 +15,27 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type NoteHandler struct {
 	githubService *services.GitHubService
+	refresher     *services.PRRefresher
+	access        *services.AccessChecker
+
+	revisionKeepLast int
+	revisionKeepDays int
+	trashRetention   time.Duration
 }
 
-func NewNoteHandler() *NoteHandler {
+func NewNoteHandler(cfg *config.Config, refresher *services.PRRefresher, access *services.AccessChecker) *NoteHandler {
 	return &NoteHandler{
-		githubService: services.NewGitHubService(),
+		githubService:    services.NewGitHubService(),
+		refresher:        refresher,
+		access:           access,
+		revisionKeepLast: cfg.NoteRevisionKeepLast,
+		revisionKeepDays: cfg.NoteRevisionKeepDays,
+		trashRetention:   cfg.TrashRetention,
 	}
 }
 
@@ -44,89 +59,84 @@ func (h *NoteHandler) CreateNote(c *gin.Context) {
 		return
 	}
 
-	note := models.Note{
-		ID:             uuid.New(),
-		UserID:         userID,
-		Title:          req.Title,
-		Content:        req.Content,
-		GithubPRNumber: req.GithubPRNumber,
-		RepoOwner:      req.RepoOwner,
-		RepoName:       req.RepoName,
-	}
-
-	// If GitHub PR info is provided, fetch and store PR data
-	if req.GithubPRNumber != nil && req.RepoOwner != "" && req.RepoName != "" {
-		if user.GithubToken == "" {
-			utils.ErrorResponse(c, http.StatusBadRequest, "GitHub token is required to fetch PR information. Please update your profile first.")
-			return
-		}
-
-		// Validate GitHub PR parameters
-		if *req.GithubPRNumber <= 0 {
-			utils.ErrorResponse(c, http.StatusBadRequest, "PR number must be greater than 0")
-			return
-		}
+	note, err := h.createNote(&user, &req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
 
-		// Check if PR already exists in database
-		var existingPR models.PullRequest
-		err := database.DB.Where("number = ? AND repo_owner = ? AND repo_name = ?",
-			*req.GithubPRNumber, req.RepoOwner, req.RepoName).First(&existingPR).Error
+	utils.SuccessResponse(c, http.StatusCreated, note)
+}
 
-		if err != nil {
-			// PR doesn't exist, fetch from GitHub
-			prData, err := h.githubService.GetPullRequest(req.RepoOwner, req.RepoName, *req.GithubPRNumber, user.GithubToken)
-			if err != nil {
-				utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
-				return
-			}
+// createNote stores a new note for user and links the GitHub items the
+// request references, returning the note with its links loaded.
+func (h *NoteHandler) createNote(user *models.User, req *models.CreateNoteRequest) (*models.Note, error) {
+	note := models.Note{
+		ID:      uuid.New(),
+		UserID:  user.ID,
+		Title:   req.Title,
+		Content: req.Content,
+	}
 
-			// Create new PR record
-			newPR := models.PullRequest{
-				ID:        uuid.New(),
-				Number:    prData.Number,
-				RepoOwner: req.RepoOwner,
-				RepoName:  req.RepoName,
-				Title:     prData.Title,
-				Body:      prData.Body,
-				Author:    prData.User.Login,
-				State:     prData.State,
-				URL:       prData.HTMLURL,
-			}
+	if req.NotebookID != nil {
+		var notebook models.Notebook
+		if err := database.DB.Where("id = ? AND user_id = ?", *req.NotebookID, user.ID).First(&notebook).Error; err != nil {
+			return nil, &apiError{http.StatusNotFound, "Notebook not found"}
+		}
+		note.NotebookID = &notebook.ID
+	}
 
-			if err := database.DB.Create(&newPR).Error; err != nil {
-				utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to save PR information")
-				return
-			}
+	// If GitHub items are referenced, fetch and store their data first
+	refs, err := referencesFromRequest(&req.NoteLinks)
+	if err != nil {
+		return nil, err
+	}
 
-			existingPR = newPR
+	resolved, err := h.resolveReferences(refs, user)
+	if err != nil {
+		return nil, err
+	}
+	setNoteRepository(&note, resolved)
+	detected := h.detectLinks(&note, user)
+
+	// The note is only stored once it is linked, tagged and recorded, so a
+	// failed request can be retried without creating a duplicate
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&note).Error; err != nil {
+			return err
 		}
 
-		// Create note first
-		if err := database.DB.Create(&note).Error; err != nil {
-			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to create note")
-			return
+		if err := linkReferences(tx, &note, resolved); err != nil {
+			return err
 		}
-
-		// Create note-PR link
-		if err := database.DB.Model(&note).Association("PullRequests").Append(&existingPR); err != nil {
-			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to link note with PR")
-			return
+		if err := applyDetectedLinks(tx, &note, detected); err != nil {
+			return err
 		}
-	} else {
-		// Create note without PR information
-		if err := database.DB.Create(&note).Error; err != nil {
-			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to create note")
-			return
+		if req.SnapshotPR {
+			if err := snapshotLinks(tx, &note, append(resolved, detected.resolved...)); err != nil {
+				return err
+			}
 		}
+		if len(req.Tags) > 0 {
+			if err := setNoteTags(tx, &note, req.Tags); err != nil {
+				return err
+			}
+		}
+		return h.recordRevision(tx, &note, user.ID)
+	})
+	if err != nil {
+		return nil, asAPIError(err, "Failed to create note")
 	}
 
 	// Fetch the created note with associations
-	if err := database.DB.Preload("PullRequests").First(&note, note.ID).Error; err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch created note")
-		return
+	if err := database.DB.Scopes(withNoteLinks).First(&note, note.ID).Error; err != nil {
+		return nil, &apiError{http.StatusInternalServerError, "Failed to fetch created note"}
 	}
 
-	utils.SuccessResponse(c, http.StatusCreated, note)
+	notes := []models.Note{note}
+	restrictUnreadableLinks(h.access, notes, user)
+
+	return &notes[0], nil
 }
 
 func (h *NoteHandler) GetNotes(c *gin.Context) {
@@ -136,46 +146,107 @@ func (h *NoteHandler) GetNotes(c *gin.Context) {
 		return
 	}
 
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "User not found")
+		return
+	}
+
 	page, limit, offset := utils.GetPaginationParams(c)
 
 	// Get search parameters
 	search := c.Query("search")
 	prNumber := c.Query("pr_number")
 	prState := c.Query("pr_state")
+	issueState := c.Query("issue_state")
+	commitSHA := strings.ToLower(c.Query("commit_sha"))
+	sourceUnavailable := c.Query("source_unavailable") == "true"
+	tags := splitTagNames(c.Query("tags"))
+	tagMode := c.DefaultQuery("tag_mode", "any")
+	notebookID := c.Query("notebook_id")
+	includeSubNotebooks := c.Query("include_sub_notebooks") == "true"
 
-	query := database.DB.Where("user_id = ?", userID)
+	query := database.DB.Where("notes.user_id = ?", userID)
 
 	// Apply filters
 	if search != "" {
-		query = query.Where("title ILIKE ? OR content ILIKE ?", "%"+search+"%", "%"+search+"%")
+		query = query.Where("notes.title ILIKE ? OR notes.content ILIKE ?", "%"+search+"%", "%"+search+"%")
 	}
 
 	if prNumber != "" {
 		if num, err := strconv.Atoi(prNumber); err == nil {
-			query = query.Where("github_pr_number = ?", num)
+			query = query.Where("EXISTS (SELECT 1 FROM note_pr_links JOIN pull_requests ON pull_requests.id = note_pr_links.pr_id WHERE note_pr_links.note_id = notes.id AND pull_requests.number = ?)", num)
 		}
 	}
 
 	if prState != "" {
-		// Join with pull_requests table to filter by PR state
-		query = query.Joins("JOIN note_pr_links ON notes.id = note_pr_links.note_id").
-			Joins("JOIN pull_requests ON note_pr_links.pr_id = pull_requests.id").
-			Where("pull_requests.state = ?", prState)
+		query = query.Where("EXISTS (SELECT 1 FROM note_pr_links JOIN pull_requests ON pull_requests.id = note_pr_links.pr_id WHERE note_pr_links.note_id = notes.id AND pull_requests.state = ?)", prState)
+	}
+
+	if issueState != "" {
+		query = query.Where("EXISTS (SELECT 1 FROM note_issue_links JOIN issues ON issues.id = note_issue_links.issue_id WHERE note_issue_links.note_id = notes.id AND issues.state = ?)", issueState)
+	}
+
+	if commitSHA != "" {
+		// Match commits by SHA prefix so short SHAs work as well
+		query = query.Where("EXISTS (SELECT 1 FROM note_commit_links JOIN commits ON commits.id = note_commit_links.commit_id WHERE note_commit_links.note_id = notes.id AND commits.sha LIKE ?)", commitSHA+"%")
+	}
+
+	if sourceUnavailable {
+		// Notes with at least one linked PR that can no longer be fetched
+		query = query.Where("EXISTS (SELECT 1 FROM note_pr_links JOIN pull_requests ON pull_requests.id = note_pr_links.pr_id WHERE note_pr_links.note_id = notes.id AND pull_requests.fetch_status IN ?)",
+			models.UnavailableFetchStatuses)
+	}
+
+	if len(tags) > 0 {
+		// any: at least one of the tags; all: every one of them
+		switch tagMode {
+		case "any":
+			query = query.Where("EXISTS (SELECT 1 FROM note_tags JOIN tags ON tags.id = note_tags.tag_id WHERE note_tags.note_id = notes.id AND LOWER(tags.name) IN ?)", tags)
+		case "all":
+			query = query.Where("(SELECT COUNT(*) FROM note_tags JOIN tags ON tags.id = note_tags.tag_id WHERE note_tags.note_id = notes.id AND LOWER(tags.name) IN ?) = ?", tags, len(tags))
+		default:
+			utils.ErrorResponse(c, http.StatusBadRequest, "tag_mode must be any or all")
+			return
+		}
+	}
+
+	// notebook_id=none lists the notes not filed in any notebook
+	if notebookID == "none" {
+		query = query.Where("notebook_id IS NULL")
+	} else if notebookID != "" {
+		id, err := uuid.Parse(notebookID)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid notebook ID")
+			return
+		}
+		ids := []uuid.UUID{id}
+		if includeSubNotebooks {
+			notebooks, err := loadNotebooks(userID)
+			if err != nil {
+				utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch notebooks")
+				return
+			}
+			ids = notebookSubtreeIDs(notebooks, id)
+		}
+		query = query.Where("notebook_id IN ?", ids)
 	}
 
 	var total int64
 	query.Model(&models.Note{}).Count(&total)
 
 	var notes []models.Note
-	if err := query.Preload("PullRequests").
+	if err := query.Scopes(withNoteLinks).
 		Offset(offset).
 		Limit(limit).
-		Order("created_at DESC").
+		Order("notes.created_at DESC").
 		Find(&notes).Error; err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch notes")
 		return
 	}
 
+	presentNotes(h.refresher, h.access, notes, &user)
+
 	response := models.NotesResponse{
 		Notes: notes,
 		Total: total,
@@ -202,13 +273,22 @@ func (h *NoteHandler) GetNote(c *gin.Context) {
 
 	var note models.Note
 	if err := database.DB.Where("id = ? AND user_id = ?", noteID, userID).
-		Preload("PullRequests").
+		Scopes(withNoteLinks).
 		First(&note).Error; err != nil {
 		utils.ErrorResponse(c, http.StatusNotFound, "Note not found")
 		return
 	}
 
-	utils.SuccessResponse(c, http.StatusOK, note)
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "User not found")
+		return
+	}
+
+	notes := []models.Note{note}
+	presentNotes(h.refresher, h.access, notes, &user)
+
+	utils.SuccessResponse(c, http.StatusOK, notes[0])
 }
 
 func (h *NoteHandler) UpdateNote(c *gin.Context) {
@@ -240,74 +320,87 @@ func (h *NoteHandler) UpdateNote(c *gin.Context) {
 	// Update basic fields
 	note.Title = req.Title
 	note.Content = req.Content
-	note.GithubPRNumber = req.GithubPRNumber
-	note.RepoOwner = req.RepoOwner
-	note.RepoName = req.RepoName
-
-	// Handle PR information update
-	if req.GithubPRNumber != nil && req.RepoOwner != "" && req.RepoName != "" {
-		// Get user for GitHub token
-		var user models.User
-		if err := database.DB.First(&user, userID).Error; err != nil {
-			utils.ErrorResponse(c, http.StatusNotFound, "User not found")
-			return
-		}
 
-		if user.GithubToken == "" {
-			utils.ErrorResponse(c, http.StatusBadRequest, "GitHub token is required to fetch PR information")
+	// Handle GitHub reference update
+	refs, err := referencesFromRequest(&req.NoteLinks)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	// Get user for GitHub token
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "User not found")
+		return
+	}
+
+	// Replace existing associations when the request references anything;
+	// an explicit empty github_refs list removes them all
+	replaceLinks := len(refs) > 0 || req.GithubRefs != nil
+	var resolved []*resolvedReference
+	if replaceLinks {
+		resolved, err = h.resolveReferences(refs, &user)
+		if err != nil {
+			respondError(c, err)
 			return
 		}
+		setNoteRepository(&note, resolved)
+	}
 
-		// Clear existing PR associations
-		database.DB.Model(&note).Association("PullRequests").Clear()
-
-		// Check if PR exists or fetch new one
-		var existingPR models.PullRequest
-		err := database.DB.Where("number = ? AND repo_owner = ? AND repo_name = ?",
-			*req.GithubPRNumber, req.RepoOwner, req.RepoName).First(&existingPR).Error
+	// References in the content are kept in sync on every save
+	detected := h.detectLinks(&note, &user)
 
-		if err != nil {
-			// Fetch from GitHub
-			prData, err := h.githubService.GetPullRequest(req.RepoOwner, req.RepoName, *req.GithubPRNumber, user.GithubToken)
-			if err != nil {
-				utils.ErrorResponse(c, http.StatusBadRequest, "Failed to fetch PR information from GitHub: "+err.Error())
-				return
+	// Resolving may call the code host, so it is done before the update
+	// transaction is opened; the note is changed only if all of it succeeds
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if replaceLinks {
+			if err := clearReferences(tx, &note); err != nil {
+				return err
 			}
+			if err := linkReferences(tx, &note, resolved); err != nil {
+				return err
+			}
+		}
 
-			existingPR = models.PullRequest{
-				ID:        uuid.New(),
-				Number:    prData.Number,
-				RepoOwner: req.RepoOwner,
-				RepoName:  req.RepoName,
-				Title:     prData.Title,
-				Body:      prData.Body,
-				Author:    prData.User.Login,
-				State:     prData.State,
-				URL:       prData.HTMLURL,
+		if err := applyDetectedLinks(tx, &note, detected); err != nil {
+			return err
+		}
+		if req.SnapshotPR {
+			if err := snapshotLinks(tx, &note, append(resolved, detected.resolved...)); err != nil {
+				return err
 			}
+		}
+		if err := pruneSnapshots(tx, &note); err != nil {
+			return err
+		}
 
-			if err := database.DB.Create(&existingPR).Error; err != nil {
-				utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to save PR information")
-				return
+		if req.Tags != nil {
+			if err := setNoteTags(tx, &note, req.Tags); err != nil {
+				return err
 			}
 		}
 
-		// Associate with note
-		database.DB.Model(&note).Association("PullRequests").Append(&existingPR)
-	} else {
-		// Clear PR associations if no PR info provided
-		database.DB.Model(&note).Association("PullRequests").Clear()
+		if err := tx.Save(&note).Error; err != nil {
+			return err
+		}
+		return h.recordRevision(tx, &note, userID)
+	})
+	if err != nil {
+		respondError(c, asAPIError(err, "Failed to update note"))
+		return
 	}
 
-	if err := database.DB.Save(&note).Error; err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update note")
+	// Fetch updated note with associations
+	if err := database.DB.Scopes(withNoteLinks).First(&note, note.ID).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch updated note")
 		return
 	}
 
-	// Fetch updated note with associations
-	database.DB.Preload("PullRequests").First(&note, note.ID)
+	notes := []models.Note{note}
+	restrictUnreadableLinks(h.access, notes, &user)
 
-	utils.SuccessResponse(c, http.StatusOK, note)
+	utils.SuccessResponse(c, http.StatusOK, notes[0])
 }
 
 func (h *NoteHandler) DeleteNote(c *gin.Context) {
@@ -330,14 +423,12 @@ func (h *NoteHandler) DeleteNote(c *gin.Context) {
 		return
 	}
 
-	// Clear associations first
-	database.DB.Model(&note).Association("PullRequests").Clear()
-
-	// Delete the note
+	// Links are kept so restoring the note brings them back; the trash
+	// purge removes them along with the note
 	if err := database.DB.Delete(&note).Error; err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete note")
 		return
 	}
 
-	utils.SuccessResponse(c, http.StatusOK, gin.H{"message": "Note deleted successfully"})
+	utils.SuccessResponse(c, http.StatusOK, gin.H{"message": "Note moved to trash"})
 }
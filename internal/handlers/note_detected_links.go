@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"fmt"
+
+	"github-notes-backend/internal/database"
+	"github-notes-backend/internal/models"
+	"github-notes-backend/internal/services"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// detectedLinks are the references found in a note's content.
+type detectedLinks struct {
+	resolved   []*resolvedReference
+	referenced map[string]bool
+	prIDs      []uuid.UUID
+	issueIDs   []uuid.UUID
+}
+
+// detectLinks resolves the references in the note content, fetching them from
+// the code host when they are not cached. References that cannot be
+// resolved, e.g. because the user cannot read them, are skipped so they never
+// block saving the note.
+func (h *NoteHandler) detectLinks(note *models.Note, user *models.User) *detectedLinks {
+	var defaultRepo *models.Repository
+	if note.RepositoryID != nil {
+		var repo models.Repository
+		if err := database.DB.First(&repo, *note.RepositoryID).Error; err == nil {
+			defaultRepo = &repo
+		}
+	}
+
+	detected := &detectedLinks{
+		referenced: make(map[string]bool),
+		prIDs:      []uuid.UUID{},
+		issueIDs:   []uuid.UUID{},
+	}
+	for _, ref := range services.ExtractReferences(note.Content, defaultRepo) {
+		detected.referenced[detectedKey(ref.Host, ref.RepoOwner, ref.RepoName, ref.Number)] = true
+
+		r := h.resolveDetectedReference(&ref, user)
+		if r == nil {
+			continue
+		}
+		detected.resolved = append(detected.resolved, r)
+		if r.PullRequest != nil {
+			detected.prIDs = append(detected.prIDs, r.PullRequest.ID)
+		}
+		if r.Issue != nil {
+			detected.issueIDs = append(detected.issueIDs, r.Issue.ID)
+		}
+	}
+	return detected
+}
+
+// applyDetectedLinks links the PRs and issues referenced in the note content
+// and unlinks the ones an earlier version referenced but this one does not.
+// Links made through the API are never removed here, and references that
+// could not be resolved keep their existing links.
+func applyDetectedLinks(db *gorm.DB, note *models.Note, detected *detectedLinks) error {
+	// Drop what the content no longer references. Links are matched on the
+	// text as well as on what resolved, so a reference that could not be
+	// resolved this time, e.g. because GitHub was rate limited, keeps its link.
+	kept := make(map[uuid.UUID]bool, len(detected.prIDs)+len(detected.issueIDs))
+	for _, id := range detected.prIDs {
+		kept[id] = true
+	}
+	for _, id := range detected.issueIDs {
+		kept[id] = true
+	}
+
+	var linkedPRs []models.PullRequest
+	if err := db.Joins("JOIN note_pr_links ON note_pr_links.pr_id = pull_requests.id").
+		Where("note_pr_links.note_id = ? AND note_pr_links.detected", note.ID).
+		Find(&linkedPRs).Error; err != nil {
+		return err
+	}
+	stalePRs := []uuid.UUID{}
+	for _, pr := range linkedPRs {
+		if !kept[pr.ID] && !detected.referenced[detectedKey(pr.Host, pr.RepoOwner, pr.RepoName, pr.Number)] {
+			stalePRs = append(stalePRs, pr.ID)
+		}
+	}
+	if len(stalePRs) > 0 {
+		if err := db.Where("note_id = ? AND detected AND pr_id IN ?", note.ID, stalePRs).
+			Delete(&models.NotePRLink{}).Error; err != nil {
+			return err
+		}
+	}
+
+	var linkedIssues []models.Issue
+	if err := db.Joins("JOIN note_issue_links ON note_issue_links.issue_id = issues.id").
+		Where("note_issue_links.note_id = ? AND note_issue_links.detected", note.ID).
+		Find(&linkedIssues).Error; err != nil {
+		return err
+	}
+	staleIssues := []uuid.UUID{}
+	for _, issue := range linkedIssues {
+		if !kept[issue.ID] && !detected.referenced[detectedKey(issue.Host, issue.RepoOwner, issue.RepoName, issue.Number)] {
+			staleIssues = append(staleIssues, issue.ID)
+		}
+	}
+	if len(staleIssues) > 0 {
+		if err := db.Where("note_id = ? AND detected AND issue_id IN ?", note.ID, staleIssues).
+			Delete(&models.NoteIssueLink{}).Error; err != nil {
+			return err
+		}
+	}
+
+	// Existing links, detected or not, are left as they are
+	for _, id := range detected.prIDs {
+		if err := db.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.NotePRLink{NoteID: note.ID, PRID: id, Detected: true}).Error; err != nil {
+			return err
+		}
+	}
+	for _, id := range detected.issueIDs {
+		if err := db.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.NoteIssueLink{NoteID: note.ID, IssueID: id, Detected: true}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// detectedKey identifies a PR or issue referenced in note content.
+func detectedKey(host, owner, repo string, number int) string {
+	return fmt.Sprintf("%s/%s/%s#%d", host, owner, repo, number)
+}
+
+// resolveDetectedReference resolves a reference found in note content, or
+// returns nil when it cannot be resolved. owner/repo#N and #N may name a PR or
+// an issue: a cached issue settles it, otherwise the PR is tried first.
+func (h *NoteHandler) resolveDetectedReference(ref *models.Reference, user *models.User) *resolvedReference {
+	if ref.Kind == "" {
+		ref.Kind = models.RefPullRequest
+
+		var cached int64
+		database.DB.Model(&models.Issue{}).
+			Where("host = ? AND number = ? AND repo_owner = ? AND repo_name = ?", ref.Host, ref.Number, ref.RepoOwner, ref.RepoName).
+			Count(&cached)
+		if cached > 0 {
+			ref.Kind = models.RefIssue
+		} else {
+			asPR := *ref
+			if r, err := h.resolveReference(&asPR, user); err == nil {
+				return r
+			}
+			ref.Kind = models.RefIssue
+		}
+	}
+
+	r, err := h.resolveReference(ref, user)
+	if err != nil {
+		return nil
+	}
+	return r
+}
+
+// keepLinks marks the note's links to the items in resolved as made through
+// the API, so editing the content no longer removes them.
+func keepLinks(db *gorm.DB, note *models.Note, resolved []*resolvedReference) error {
+	for _, r := range resolved {
+		prIDs := []uuid.UUID{}
+		if r.PullRequest != nil {
+			prIDs = append(prIDs, r.PullRequest.ID)
+		}
+		for _, pr := range r.CommitPullRequests {
+			prIDs = append(prIDs, pr.ID)
+		}
+		if len(prIDs) > 0 {
+			if err := db.Model(&models.NotePRLink{}).
+				Where("note_id = ? AND pr_id IN ?", note.ID, prIDs).
+				Update("detected", false).Error; err != nil {
+				return err
+			}
+		}
+		if r.Issue != nil {
+			if err := db.Model(&models.NoteIssueLink{}).
+				Where("note_id = ? AND issue_id = ?", note.ID, r.Issue.ID).
+				Update("detected", false).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github-notes-backend/internal/database"
+	"github-notes-backend/internal/middleware"
+	"github-notes-backend/internal/models"
+	"github-notes-backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// AddNoteLink links one more GitHub item to a note, leaving its other links
+// in place.
+func (h *NoteHandler) AddNoteLink(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	noteID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid note ID")
+		return
+	}
+
+	var req models.AddNoteLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var note models.Note
+	if err := database.DB.Where("id = ? AND user_id = ?", noteID, userID).First(&note).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Note not found")
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "User not found")
+		return
+	}
+
+	ref, err := expandReference(&req.Reference)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	r, err := h.resolveReference(ref, &user)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	resolved := []*resolvedReference{r}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := linkReferences(tx, &note, resolved); err != nil {
+			return err
+		}
+		if err := keepLinks(tx, &note, resolved); err != nil {
+			return err
+		}
+		if req.SnapshotPR {
+			if err := snapshotLinks(tx, &note, resolved); err != nil {
+				return err
+			}
+		}
+
+		if note.RepositoryID == nil && r.Repository != nil {
+			note.RepositoryID = &r.Repository.ID
+			if err := tx.Model(&note).Update("repository_id", note.RepositoryID).Error; err != nil {
+				return err
+			}
+		}
+		return h.recordRevision(tx, &note, userID)
+	})
+	if err != nil {
+		respondError(c, asAPIError(err, "Failed to link item"))
+		return
+	}
+
+	if err := database.DB.Scopes(withNoteLinks).First(&note, note.ID).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch note")
+		return
+	}
+	h.respondWithNote(c, &note, &user)
+}
+
+// RemoveNoteLink unlinks a single PR, issue or commit from a note. kind is
+// pull_request, issue or commit and linkId the id of the cached item.
+func (h *NoteHandler) RemoveNoteLink(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	noteID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid note ID")
+		return
+	}
+
+	linkID, err := uuid.Parse(c.Param("linkId"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid link ID")
+		return
+	}
+
+	var note models.Note
+	if err := database.DB.Where("id = ? AND user_id = ?", noteID, userID).
+		Scopes(withNoteLinks).
+		First(&note).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Note not found")
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "User not found")
+		return
+	}
+
+	var association string
+	var linked interface{}
+	switch c.Param("kind") {
+	case models.RefPullRequest:
+		association = "PullRequests"
+		for i := range note.PullRequests {
+			if note.PullRequests[i].ID == linkID {
+				linked = &note.PullRequests[i]
+			}
+		}
+	case models.RefIssue:
+		association = "Issues"
+		for i := range note.Issues {
+			if note.Issues[i].ID == linkID {
+				linked = &note.Issues[i]
+			}
+		}
+	case models.RefCommit:
+		association = "Commits"
+		for i := range note.Commits {
+			if note.Commits[i].ID == linkID {
+				linked = &note.Commits[i]
+			}
+		}
+	default:
+		utils.ErrorResponse(c, http.StatusBadRequest, "Link kind must be pull_request, issue or commit")
+		return
+	}
+	if linked == nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Note is not linked to that item")
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&note).Association(association).Delete(linked); err != nil {
+			return err
+		}
+		if err := pruneSnapshots(tx, &note); err != nil {
+			return err
+		}
+
+		// Re-point the note at the repository of a remaining link
+		if err := tx.Scopes(withNoteLinks).First(&note, note.ID).Error; err != nil {
+			return err
+		}
+		note.RepositoryID = noteRepositoryFromLinks(tx, &note)
+		if err := tx.Model(&note).Update("repository_id", note.RepositoryID).Error; err != nil {
+			return err
+		}
+		return h.recordRevision(tx, &note, userID)
+	})
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to unlink item")
+		return
+	}
+
+	h.respondWithNote(c, &note, &user)
+}
+
+// respondWithNote writes a note loaded with its links, restricting the linked
+// PRs the user cannot read.
+func (h *NoteHandler) respondWithNote(c *gin.Context, note *models.Note, user *models.User) {
+	notes := []models.Note{*note}
+	restrictUnreadableLinks(h.access, notes, user)
+
+	utils.SuccessResponse(c, http.StatusOK, notes[0])
+}
+
+// noteRepositoryFromLinks returns the repository of the note's first linked
+// PR that has one, falling back to the repository of a linked issue or
+// commit. The note's links must be loaded.
+func noteRepositoryFromLinks(db *gorm.DB, note *models.Note) *uuid.UUID {
+	for _, pr := range note.PullRequests {
+		if pr.RepositoryID != nil {
+			return pr.RepositoryID
+		}
+	}
+
+	// Issues and commits are only cached from GitHub hosts
+	var keys [][]interface{}
+	for _, issue := range note.Issues {
+		keys = append(keys, []interface{}{issue.Host, issue.RepoOwner, issue.RepoName})
+	}
+	for _, commit := range note.Commits {
+		keys = append(keys, []interface{}{models.DefaultGitHubHost, commit.RepoOwner, commit.RepoName})
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	var repo models.Repository
+	if err := db.Where("provider = ?", models.ProviderGitHub).
+		Where("(host, owner, name) IN ?", keys).
+		First(&repo).Error; err != nil {
+		return nil
+	}
+	return &repo.ID
+}
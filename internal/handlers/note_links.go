@@ -0,0 +1,577 @@
+package handlers
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github-notes-backend/internal/database"
+	"github-notes-backend/internal/models"
+	"github-notes-backend/internal/services"
+	"github-notes-backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// apiError carries the HTTP status a handler should respond with.
+type apiError struct {
+	Status  int
+	Message string
+}
+
+func (e *apiError) Error() string {
+	return e.Message
+}
+
+// errPullRequestNotReadable hides whether a private PR is cached from users
+// who cannot read it.
+var errPullRequestNotReadable = &apiError{http.StatusNotFound, "Pull request not found or not accessible with your credentials"}
+
+var errIssueNotReadable = &apiError{http.StatusNotFound, "Issue not found or not accessible with your credentials"}
+
+// commitSHAPattern matches full and abbreviated commit SHAs. GitHub needs at
+// least 7 characters to resolve an abbreviated one.
+var commitSHAPattern = regexp.MustCompile(`^[0-9a-fA-F]{7,40}$`)
+
+var errCommitNotReadable = &apiError{http.StatusNotFound, "Commit not found or not accessible with your credentials"}
+
+// resolvedReference is a GitHub reference backed by a cached row.
+type resolvedReference struct {
+	PullRequest *models.PullRequest
+	Issue       *models.Issue
+	Commit      *models.Commit
+	Repository  *models.Repository
+	// CommitPullRequests are cached PRs the linked commit belongs to
+	CommitPullRequests []models.PullRequest
+}
+
+// referencesFromRequest returns the GitHub references described by a create or
+// update request: every github_refs entry, github_ref, pr_url, the legacy
+// github_pr_number/repo_owner/repo_name fields and commit_ref, in that order.
+func referencesFromRequest(links *models.NoteLinks) ([]*models.Reference, error) {
+	var refs []*models.Reference
+
+	for i := range links.GithubRefs {
+		ref, err := expandReference(&links.GithubRefs[i])
+		if err != nil {
+			return nil, err
+		}
+		refs = append(refs, ref)
+	}
+
+	if links.GithubRef != nil {
+		ref, err := expandReference(links.GithubRef)
+		if err != nil {
+			return nil, err
+		}
+		refs = append(refs, ref)
+	}
+
+	if links.PRURL != "" {
+		ref, err := expandReference(&models.Reference{URL: links.PRURL})
+		if err != nil {
+			return nil, err
+		}
+		refs = append(refs, ref)
+	}
+
+	// The legacy fields are only a fallback for clients that send nothing newer
+	if links.GithubRef == nil && links.PRURL == "" && links.GithubPRNumber != nil && links.RepoOwner != "" && links.RepoName != "" {
+		refs = append(refs, &models.Reference{
+			Kind:      models.RefPullRequest,
+			RepoOwner: links.RepoOwner,
+			RepoName:  links.RepoName,
+			Number:    *links.GithubPRNumber,
+		})
+	}
+
+	if links.CommitRef != "" {
+		commit, err := services.ParseCommitRef(links.CommitRef)
+		if err != nil {
+			return nil, &apiError{http.StatusBadRequest, err.Error()}
+		}
+		refs = append(refs, commit)
+	}
+
+	return refs, nil
+}
+
+// expandReference returns a copy of ref with its kind defaulted to a pull
+// request and, when it is given as a URL, the URL parsed into its parts.
+func expandReference(ref *models.Reference) (*models.Reference, error) {
+	expanded := *ref
+	if expanded.Kind == "" {
+		expanded.Kind = models.RefPullRequest
+	}
+	if expanded.URL != "" {
+		parsed, err := services.ParseChangeRequestURL(expanded.URL)
+		if err != nil {
+			return nil, &apiError{http.StatusBadRequest, err.Error()}
+		}
+		expanded.Kind = models.RefPullRequest
+		expanded.Provider = parsed.Provider
+		expanded.Host = parsed.Host
+		expanded.RepoOwner = parsed.Owner
+		expanded.RepoName = parsed.Repo
+		expanded.Number = parsed.Number
+	}
+	return &expanded, nil
+}
+
+// resolveReferences resolves every reference, stopping at the first failure.
+func (h *NoteHandler) resolveReferences(refs []*models.Reference, user *models.User) ([]*resolvedReference, error) {
+	resolved := make([]*resolvedReference, 0, len(refs))
+	for _, ref := range refs {
+		r, err := h.resolveReference(ref, user)
+		if err != nil {
+			return nil, err
+		}
+		resolved = append(resolved, r)
+	}
+	return resolved, nil
+}
+
+// resolveReference finds the cached row for ref, fetching it from the code
+// host with the user's credential when it is not cached yet.
+func (h *NoteHandler) resolveReference(ref *models.Reference, user *models.User) (*resolvedReference, error) {
+	ref.Provider = services.NormalizeProvider(ref.Provider)
+	ref.Host = models.NormalizeIdentifier(ref.Host)
+	if ref.Provider == models.ProviderGitHub && ref.Host == "" {
+		ref.Host = models.DefaultGitHubHost
+	}
+	ref.RepoOwner = models.NormalizeIdentifier(ref.RepoOwner)
+	ref.RepoName = models.NormalizeIdentifier(ref.RepoName)
+
+	if ref.Provider != models.ProviderGitHub && ref.Kind != models.RefPullRequest {
+		return nil, &apiError{http.StatusBadRequest, "Only pull or merge requests can be linked from " + ref.Provider}
+	}
+
+	// Commits are only cached from github.com
+	if ref.Kind == models.RefCommit && !models.IsGitHubDotCom(ref.Provider, ref.Host) {
+		return nil, &apiError{http.StatusBadRequest, "Only pull requests and issues can be linked from " + ref.Host}
+	}
+
+	if models.IsGitHubDotCom(ref.Provider, ref.Host) && user.GithubToken == "" {
+		return nil, &apiError{http.StatusBadRequest, "GitHub token is required to fetch PR information. Please update your profile first."}
+	}
+
+	resolved, err := h.resolveItem(ref, user)
+	if err != nil {
+		return nil, err
+	}
+
+	// The repository registry is best effort; a missing row never blocks linking
+	repo, err := services.EnsureRepository(h.githubService, ref.Provider, ref.Host, ref.RepoOwner, ref.RepoName, user.GithubToken)
+	if err == nil {
+		resolved.Repository = repo
+		if resolved.PullRequest != nil && resolved.PullRequest.RepositoryID == nil {
+			resolved.PullRequest.RepositoryID = &repo.ID
+			database.DB.Model(resolved.PullRequest).Update("repository_id", repo.ID)
+		}
+	}
+
+	return resolved, nil
+}
+
+func (h *NoteHandler) resolveItem(ref *models.Reference, user *models.User) (*resolvedReference, error) {
+	switch ref.Kind {
+	case models.RefCommit:
+		if ref.SHA == "" {
+			return nil, &apiError{http.StatusBadRequest, "Commit SHA is required"}
+		}
+		if !commitSHAPattern.MatchString(ref.SHA) {
+			return nil, &apiError{http.StatusBadRequest, "Commit SHA must be 7 to 40 hexadecimal characters"}
+		}
+		commit, prs, err := h.findOrFetchCommit(ref, user)
+		if err != nil {
+			return nil, err
+		}
+		return &resolvedReference{Commit: commit, CommitPullRequests: h.readablePullRequests(prs, user)}, nil
+	case models.RefIssue:
+		if ref.Number <= 0 {
+			return nil, &apiError{http.StatusBadRequest, "Issue number must be greater than 0"}
+		}
+		issue, err := h.findOrFetchIssue(ref, user)
+		if err != nil {
+			return nil, err
+		}
+		return &resolvedReference{Issue: issue}, nil
+	default:
+		if ref.Number <= 0 {
+			return nil, &apiError{http.StatusBadRequest, "PR number must be greater than 0"}
+		}
+		pr, err := h.findOrFetchPullRequest(ref, user)
+		if err != nil {
+			return nil, err
+		}
+		return &resolvedReference{PullRequest: pr}, nil
+	}
+}
+
+func (h *NoteHandler) findOrFetchPullRequest(ref *models.Reference, user *models.User) (*models.PullRequest, error) {
+	client, err := providerForUser(h.githubService, user, ref.Provider, ref.Host)
+	if err != nil {
+		return nil, err
+	}
+	ref.Host = client.Host
+
+	// Check if PR already exists in database
+	var existingPR models.PullRequest
+	err = database.DB.Where("provider = ? AND host = ? AND number = ? AND repo_owner = ? AND repo_name = ?",
+		ref.Provider, ref.Host, ref.Number, ref.RepoOwner, ref.RepoName).First(&existingPR).Error
+	if err == nil {
+		// The cache is shared; private PRs are only served to users who can read them
+		if !h.access.CanRead(user, &existingPR) {
+			return nil, errPullRequestNotReadable
+		}
+
+		// Serve the cached copy, refreshing it first once its TTL has expired
+		if h.refresher.IsStale(&existingPR) {
+			h.refresher.Refresh([]*models.PullRequest{&existingPR}, user, models.EventSourcePoll)
+		}
+		return &existingPR, nil
+	}
+
+	// PR doesn't exist, fetch it from the code host
+	newPR, err := client.Provider.FetchChangeRequest(services.ChangeRequestRef{
+		Provider: ref.Provider,
+		Host:     ref.Host,
+		Owner:    ref.RepoOwner,
+		Repo:     ref.RepoName,
+		Number:   ref.Number,
+	}, client.Token)
+	if err != nil {
+		return nil, &apiError{http.StatusBadRequest, err.Error()}
+	}
+
+	// A renamed repository is cached under its current name, where the PR
+	// may already be cached; the upsert refreshes that row with what was
+	// just fetched
+	ref.RepoOwner, ref.RepoName = newPR.RepoOwner, newPR.RepoName
+
+	services.MarkFetched(newPR)
+	if err := services.UpsertPullRequest(newPR); err != nil {
+		return nil, &apiError{http.StatusInternalServerError, "Failed to save PR information"}
+	}
+	h.access.Grant(user.ID, newPR)
+
+	return newPR, nil
+}
+
+func (h *NoteHandler) findOrFetchIssue(ref *models.Reference, user *models.User) (*models.Issue, error) {
+	var existingIssue models.Issue
+	err := database.DB.Where("host = ? AND number = ? AND repo_owner = ? AND repo_name = ?",
+		ref.Host, ref.Number, ref.RepoOwner, ref.RepoName).First(&existingIssue).Error
+	if err == nil {
+		// The cache is shared; private issues are only served to users who can read them
+		if !h.access.CanReadIssue(user, &existingIssue) {
+			return nil, errIssueNotReadable
+		}
+		return &existingIssue, nil
+	}
+
+	// Issues live on github.com or a GitHub Enterprise Server instance
+	client, err := providerForUser(h.githubService, user, models.ProviderGitHub, ref.Host)
+	if err != nil {
+		return nil, err
+	}
+	github := client.Provider.(*services.GitHubProvider).Service()
+
+	issueData, err := github.GetIssue(ref.RepoOwner, ref.RepoName, ref.Number, client.Token)
+	if err != nil {
+		return nil, &apiError{http.StatusBadRequest, err.Error()}
+	}
+
+	// The issues API also returns pull requests; those belong in the PR cache
+	if issueData.PullRequest != nil {
+		return nil, &apiError{http.StatusBadRequest, "Issue reference points to a pull request. Use kind \"pull_request\" instead."}
+	}
+
+	newIssue := services.IssueFromGithub(ref.RepoOwner, ref.RepoName, issueData)
+	newIssue.Host = ref.Host
+	newIssue.Private = github.IsPrivateRepository(newIssue.RepoOwner, newIssue.RepoName, client.Token)
+
+	// A renamed repository is cached under its current name, where the
+	// issue may already be cached; the upsert refreshes that row
+	ref.RepoOwner, ref.RepoName = newIssue.RepoOwner, newIssue.RepoName
+	if err := services.UpsertIssue(&newIssue); err != nil {
+		return nil, &apiError{http.StatusInternalServerError, "Failed to save issue information"}
+	}
+	h.access.GrantIssue(user.ID, &newIssue)
+
+	return &newIssue, nil
+}
+
+// findOrFetchCommit returns the cached commit for ref along with any cached
+// PRs it belongs to. Only full SHAs are served from the cache: an abbreviated
+// SHA may be ambiguous, so GitHub resolves it to the full SHA first.
+func (h *NoteHandler) findOrFetchCommit(ref *models.Reference, user *models.User) (*models.Commit, []models.PullRequest, error) {
+	sha := strings.ToLower(ref.SHA)
+	token := user.GithubToken
+
+	var commit models.Commit
+	err := gorm.ErrRecordNotFound
+	if len(sha) == 40 {
+		err = database.DB.Where("repo_owner = ? AND repo_name = ? AND sha = ?", ref.RepoOwner, ref.RepoName, sha).
+			First(&commit).Error
+	}
+	if err == nil {
+		// The cache is shared; private commits are only served to users who can read them
+		if !h.access.CanReadCommit(user, &commit) {
+			return nil, nil, errCommitNotReadable
+		}
+	} else {
+		commitData, err := h.githubService.GetCommit(ref.RepoOwner, ref.RepoName, sha, token)
+		if err != nil {
+			return nil, nil, &apiError{http.StatusBadRequest, err.Error()}
+		}
+
+		// Associated PRs are best effort; a commit is still linkable without them
+		var associated []int
+		if prs, err := h.githubService.GetCommitPullRequests(ref.RepoOwner, ref.RepoName, commitData.SHA, token); err == nil {
+			for _, pr := range prs {
+				associated = append(associated, pr.Number)
+			}
+		}
+
+		commit = services.CommitFromGithub(ref.RepoOwner, ref.RepoName, commitData, associated)
+		commit.Private = h.githubService.IsPrivateRepository(commit.RepoOwner, commit.RepoName, token)
+		ref.RepoOwner, ref.RepoName = commit.RepoOwner, commit.RepoName
+
+		// A short SHA, or a renamed repository, may have missed a commit that
+		// is cached under its full SHA and current repository name; the
+		// upsert refreshes that row
+		if err := services.UpsertCommit(&commit); err != nil {
+			return nil, nil, &apiError{http.StatusInternalServerError, "Failed to save commit information"}
+		}
+		h.access.GrantCommit(user.ID, &commit)
+	}
+
+	// Link to PRs we already have cached, either by association or head SHA
+	var prs []models.PullRequest
+	query := database.DB.Where("repo_owner = ? AND repo_name = ?", commit.RepoOwner, commit.RepoName)
+	if len(commit.AssociatedPRs) > 0 {
+		query = query.Where("number IN ? OR head_sha = ?", commit.AssociatedPRs, commit.SHA)
+	} else {
+		query = query.Where("head_sha = ?", commit.SHA)
+	}
+	query.Find(&prs)
+
+	return &commit, prs, nil
+}
+
+// readablePullRequests drops the PRs the user cannot read.
+func (h *NoteHandler) readablePullRequests(prs []models.PullRequest, user *models.User) []models.PullRequest {
+	refs := make([]*models.PullRequest, len(prs))
+	for i := range prs {
+		refs[i] = &prs[i]
+	}
+	unreadable := h.access.Unreadable(user, refs)
+
+	readable := make([]models.PullRequest, 0, len(prs))
+	for _, pr := range prs {
+		if !unreadable[pr.ID] {
+			readable = append(readable, pr)
+		}
+	}
+	return readable
+}
+
+// setNoteRepository points the note at the repository of its first
+// reference that has one.
+func setNoteRepository(note *models.Note, resolved []*resolvedReference) {
+	note.RepositoryID = nil
+	for _, r := range resolved {
+		if r.Repository != nil {
+			note.RepositoryID = &r.Repository.ID
+			return
+		}
+	}
+}
+
+// linkReferences attaches resolved references to the note.
+func linkReferences(db *gorm.DB, note *models.Note, resolved []*resolvedReference) error {
+	for _, r := range resolved {
+		if r.PullRequest != nil {
+			if err := db.Model(note).Association("PullRequests").Append(r.PullRequest); err != nil {
+				return &apiError{http.StatusInternalServerError, "Failed to link note with PR"}
+			}
+		}
+		if r.Issue != nil {
+			if err := db.Model(note).Association("Issues").Append(r.Issue); err != nil {
+				return &apiError{http.StatusInternalServerError, "Failed to link note with issue"}
+			}
+		}
+		if r.Commit != nil {
+			if err := db.Model(note).Association("Commits").Append(r.Commit); err != nil {
+				return &apiError{http.StatusInternalServerError, "Failed to link note with commit"}
+			}
+			if len(r.CommitPullRequests) > 0 {
+				if err := db.Model(note).Association("PullRequests").Append(r.CommitPullRequests); err != nil {
+					return &apiError{http.StatusInternalServerError, "Failed to link note with PR"}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// restrictUnreadableLinks blanks out, in place, the linked PRs, issues and
+// commits the user's credentials cannot read.
+func restrictUnreadableLinks(access *services.AccessChecker, notes []models.Note, user *models.User) {
+	var prs []*models.PullRequest
+	var issues []*models.Issue
+	var commits []*models.Commit
+	for i := range notes {
+		for j := range notes[i].PullRequests {
+			prs = append(prs, &notes[i].PullRequests[j])
+		}
+		for j := range notes[i].Issues {
+			issues = append(issues, &notes[i].Issues[j])
+		}
+		for j := range notes[i].Commits {
+			commits = append(commits, &notes[i].Commits[j])
+		}
+	}
+
+	unreadableIssues := access.UnreadableIssues(user, issues)
+	for _, issue := range issues {
+		if unreadableIssues[issue.ID] {
+			issue.Restrict()
+		}
+	}
+	unreadableCommits := access.UnreadableCommits(user, commits)
+	for _, commit := range commits {
+		if unreadableCommits[commit.ID] {
+			commit.Restrict()
+		}
+	}
+
+	unreadable := access.Unreadable(user, prs)
+	if len(unreadable) == 0 {
+		return
+	}
+	for _, pr := range prs {
+		if unreadable[pr.ID] {
+			pr.Restrict()
+		}
+	}
+	for i := range notes {
+		notes[i].UpdateSourceUnavailable()
+		notes[i].CompareSnapshots()
+	}
+}
+
+// refreshStaleLinks refreshes, in place, the PRs linked to notes whose cache
+// TTL has expired. A PR linked from several notes is fetched once. Failures are
+// recorded on the PR and the cached copy is served. Restricted PRs are left
+// alone.
+func refreshStaleLinks(refresher *services.PRRefresher, notes []models.Note, user *models.User) {
+	copies := make(map[uuid.UUID][]*models.PullRequest)
+	var stale []*models.PullRequest
+	for i := range notes {
+		for j := range notes[i].PullRequests {
+			pr := &notes[i].PullRequests[j]
+			if pr.Restricted {
+				continue
+			}
+			if _, seen := copies[pr.ID]; !seen && refresher.IsStale(pr) {
+				stale = append(stale, pr)
+			}
+			copies[pr.ID] = append(copies[pr.ID], pr)
+		}
+	}
+	if len(stale) == 0 {
+		return
+	}
+
+	refresher.Refresh(stale, user, models.EventSourcePoll)
+
+	for _, pr := range stale {
+		for _, other := range copies[pr.ID][1:] {
+			*other = *pr
+		}
+	}
+	for i := range notes {
+		notes[i].UpdateSourceUnavailable()
+		notes[i].CompareSnapshots()
+	}
+}
+
+// presentNotes prepares notes for the user: linked PRs the user cannot read
+// are restricted and the rest are refreshed once their TTL has expired.
+func presentNotes(refresher *services.PRRefresher, access *services.AccessChecker, notes []models.Note, user *models.User) {
+	restrictUnreadableLinks(access, notes, user)
+	refreshStaleLinks(refresher, notes, user)
+}
+
+// withNoteLinks preloads every GitHub item a note can link to, along with
+// the note's PR snapshots and tags.
+func withNoteLinks(db *gorm.DB) *gorm.DB {
+	return db.Preload("PullRequests").Preload("Issues").Preload("Commits").Preload("PRSnapshots").
+		Preload("Tags", func(db *gorm.DB) *gorm.DB { return db.Order("name") })
+}
+
+// snapshotLinks freezes every PR the note links to through resolved, replacing
+// earlier snapshots of the same PRs.
+func snapshotLinks(db *gorm.DB, note *models.Note, resolved []*resolvedReference) error {
+	var snapshots []models.NotePRSnapshot
+	for _, r := range resolved {
+		if r.PullRequest != nil {
+			snapshots = append(snapshots, models.SnapshotOf(note.ID, r.PullRequest))
+		}
+		for i := range r.CommitPullRequests {
+			snapshots = append(snapshots, models.SnapshotOf(note.ID, &r.CommitPullRequests[i]))
+		}
+	}
+	if len(snapshots) == 0 {
+		return nil
+	}
+
+	err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "note_id"}, {Name: "pull_request_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"head_sha", "title", "body", "state", "review_decision", "captured_at"}),
+	}).Create(&snapshots).Error
+	if err != nil {
+		return &apiError{http.StatusInternalServerError, "Failed to save PR snapshot"}
+	}
+	return nil
+}
+
+// pruneSnapshots drops the note's snapshots of PRs it no longer links to.
+func pruneSnapshots(db *gorm.DB, note *models.Note) error {
+	return db.Where("note_id = ?", note.ID).
+		Where("NOT EXISTS (SELECT 1 FROM note_pr_links WHERE note_pr_links.note_id = note_pr_snapshots.note_id AND note_pr_links.pr_id = note_pr_snapshots.pull_request_id)").
+		Delete(&models.NotePRSnapshot{}).Error
+}
+
+// clearReferences removes every GitHub link from the note.
+func clearReferences(db *gorm.DB, note *models.Note) error {
+	for _, association := range []string{"PullRequests", "Issues", "Commits"} {
+		if err := db.Model(note).Association(association).Clear(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// asAPIError returns err when it is an apiError and otherwise a 500 with
+// message, so database errors are not sent to the client.
+func asAPIError(err error, message string) error {
+	if _, ok := err.(*apiError); ok {
+		return err
+	}
+	return &apiError{http.StatusInternalServerError, message}
+}
+
+// respondError writes err using its apiError status when it has one.
+func respondError(c *gin.Context, err error) {
+	if apiErr, ok := err.(*apiError); ok {
+		utils.ErrorResponse(c, apiErr.Status, apiErr.Message)
+		return
+	}
+	utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+}
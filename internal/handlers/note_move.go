@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github-notes-backend/internal/database"
+	"github-notes-backend/internal/middleware"
+	"github-notes-backend/internal/models"
+	"github-notes-backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// MoveNote files a note in one of the caller's notebooks, or takes it out of
+// its notebook when notebook_id is null.
+func (h *NoteHandler) MoveNote(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	noteID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid note ID")
+		return
+	}
+
+	var req models.MoveNoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var note models.Note
+	if err := database.DB.Where("id = ? AND user_id = ?", noteID, userID).First(&note).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Note not found")
+		return
+	}
+
+	if req.NotebookID != nil {
+		var notebook models.Notebook
+		if err := database.DB.Where("id = ? AND user_id = ?", *req.NotebookID, userID).First(&notebook).Error; err != nil {
+			utils.ErrorResponse(c, http.StatusNotFound, "Notebook not found")
+			return
+		}
+	}
+
+	if err := database.DB.Model(&note).Update("notebook_id", req.NotebookID).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to move note")
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "User not found")
+		return
+	}
+
+	if err := database.DB.Scopes(withNoteLinks).First(&note, note.ID).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch note")
+		return
+	}
+	h.respondWithNote(c, &note, &user)
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github-notes-backend/internal/database"
+	"github-notes-backend/internal/middleware"
+	"github-notes-backend/internal/models"
+	"github-notes-backend/internal/services"
+	"github-notes-backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// PublishNote posts the note content on its linked PR, either as a
+// conversation comment or as a pending review. Publishing again to the same
+// PR and mode updates the existing comment instead of creating a duplicate.
+func (h *NoteHandler) PublishNote(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	noteID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid note ID")
+		return
+	}
+
+	var req models.PublishNoteRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if req.Mode == "" {
+		req.Mode = models.PublishModeComment
+	}
+
+	var note models.Note
+	if err := database.DB.Where("id = ? AND user_id = ?", noteID, userID).
+		Preload("PullRequests").
+		First(&note).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Note not found")
+		return
+	}
+
+	if strings.TrimSpace(note.Content) == "" {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Note content is empty")
+		return
+	}
+
+	pr := publishTarget(&note, req.PullRequestID)
+	if pr == nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Note is not linked to the requested pull request")
+		return
+	}
+
+	if !models.IsGitHubDotCom(pr.Provider, pr.Host) {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Publishing is only supported for github.com pull requests")
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "User not found")
+		return
+	}
+
+	if user.GithubToken == "" {
+		utils.ErrorResponse(c, http.StatusBadRequest, "GitHub token is required to publish notes. Please update your profile first.")
+		return
+	}
+
+	// Only reuse the previous comment when it lives on the same PR in the same form
+	var existingID *int64
+	if note.PublishedCommentID != nil && note.PublishedPRID != nil &&
+		*note.PublishedPRID == pr.ID && note.PublishedKind == req.Mode {
+		existingID = note.PublishedCommentID
+	}
+
+	commentID, commentURL, err := h.publishToGithub(pr, req.Mode, existingID, note.Content, user.GithubToken)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadGateway, "Failed to publish note: "+err.Error())
+		return
+	}
+
+	now := time.Now()
+	note.PublishedPRID = &pr.ID
+	note.PublishedKind = req.Mode
+	note.PublishedCommentID = &commentID
+	note.PublishedURL = commentURL
+	note.PublishedAt = &now
+
+	if err := database.DB.Model(&note).Select("PublishedPRID", "PublishedKind", "PublishedCommentID", "PublishedURL", "PublishedAt").
+		Updates(&note).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to save publish information")
+		return
+	}
+
+	database.DB.Scopes(withNoteLinks).First(&note, note.ID)
+
+	notes := []models.Note{note}
+	restrictUnreadableLinks(h.access, notes, &user)
+
+	utils.SuccessResponse(c, http.StatusOK, notes[0])
+}
+
+// publishTarget picks the PR to publish on: the requested one, or the note's
+// only linked PR when none was requested.
+func publishTarget(note *models.Note, prID *uuid.UUID) *models.PullRequest {
+	if prID == nil {
+		if len(note.PullRequests) == 1 {
+			return &note.PullRequests[0]
+		}
+		return nil
+	}
+
+	for i := range note.PullRequests {
+		if note.PullRequests[i].ID == *prID {
+			return &note.PullRequests[i]
+		}
+	}
+	return nil
+}
+
+// publishToGithub creates or updates the comment or review holding the note.
+// A previously published comment that no longer exists on GitHub is recreated.
+func (h *NoteHandler) publishToGithub(pr *models.PullRequest, mode string, existingID *int64, body, token string) (int64, string, error) {
+	if mode == models.PublishModeReview {
+		if existingID != nil {
+			review, err := h.githubService.UpdateReview(pr.RepoOwner, pr.RepoName, pr.Number, *existingID, body, token)
+			if err == nil {
+				return review.ID, review.HTMLURL, nil
+			}
+			if !services.IsNotFound(err) {
+				return 0, "", err
+			}
+		}
+		review, err := h.githubService.CreatePendingReview(pr.RepoOwner, pr.RepoName, pr.Number, body, token)
+		if err != nil {
+			return 0, "", err
+		}
+		return review.ID, review.HTMLURL, nil
+	}
+
+	if existingID != nil {
+		comment, err := h.githubService.UpdateIssueComment(pr.RepoOwner, pr.RepoName, *existingID, body, token)
+		if err == nil {
+			return comment.ID, comment.HTMLURL, nil
+		}
+		if !services.IsNotFound(err) {
+			return 0, "", err
+		}
+	}
+	comment, err := h.githubService.CreateIssueComment(pr.RepoOwner, pr.RepoName, pr.Number, body, token)
+	if err != nil {
+		return 0, "", err
+	}
+	return comment.ID, comment.HTMLURL, nil
+}
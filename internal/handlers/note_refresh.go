@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github-notes-backend/internal/database"
+	"github-notes-backend/internal/middleware"
+	"github-notes-backend/internal/models"
+	"github-notes-backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RefreshNoteLinks re-fetches every PR linked to the note right away,
+// ignoring cache TTLs, and returns the fields that changed on each. A PR that
+// fails to refresh keeps its cached data and reports the error.
+func (h *NoteHandler) RefreshNoteLinks(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	noteID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid note ID")
+		return
+	}
+
+	var note models.Note
+	if err := database.DB.Where("id = ? AND user_id = ?", noteID, userID).
+		Preload("PullRequests").
+		First(&note).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Note not found")
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "User not found")
+		return
+	}
+
+	if !allowManualRefresh(c, h.refresher, userID) {
+		return
+	}
+
+	prs := make([]*models.PullRequest, len(note.PullRequests))
+	for i := range note.PullRequests {
+		prs[i] = &note.PullRequests[i]
+	}
+	unreadable := h.access.Unreadable(&user, prs)
+
+	response := models.RefreshNoteLinksResponse{PullRequests: []models.RefreshedLink{}}
+	for _, pr := range prs {
+		link := models.RefreshedLink{PullRequestID: pr.ID, Changes: []models.FieldChange{}}
+		if unreadable[pr.ID] {
+			link.Error = errPullRequestNotReadable.Error()
+		} else if changes, err := h.refresher.RefreshOne(pr, &user, models.EventSourceManual); err != nil {
+			link.Error = err.Error()
+		} else {
+			link.Changes = changes
+		}
+		response.PullRequests = append(response.PullRequests, link)
+	}
+
+	if err := database.DB.Scopes(withNoteLinks).First(&response.Note, note.ID).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch note")
+		return
+	}
+
+	notes := []models.Note{response.Note}
+	restrictUnreadableLinks(h.access, notes, &user)
+	response.Note = notes[0]
+
+	utils.SuccessResponse(c, http.StatusOK, response)
+}
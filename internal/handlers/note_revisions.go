@@ -0,0 +1,489 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github-notes-backend/internal/database"
+	"github-notes-backend/internal/middleware"
+	"github-notes-backend/internal/models"
+	"github-notes-backend/internal/services"
+	"github-notes-backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GetRevisions lists a note's revisions, newest first.
+func (h *NoteHandler) GetRevisions(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	noteID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid note ID")
+		return
+	}
+
+	var note models.Note
+	if err := database.DB.Where("id = ? AND user_id = ?", noteID, userID).First(&note).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Note not found")
+		return
+	}
+
+	page, limit, offset := utils.GetPaginationParams(c)
+
+	query := database.DB.Model(&models.NoteRevision{}).Where("note_id = ?", note.ID)
+
+	var total int64
+	query.Count(&total)
+
+	var revisions []models.NoteRevision
+	if err := query.Order("number DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&revisions).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch revisions")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, models.NoteRevisionsResponse{
+		Revisions: revisions,
+		Total:     total,
+		Page:      page,
+		Limit:     limit,
+	})
+}
+
+func (h *NoteHandler) GetRevision(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	noteID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid note ID")
+		return
+	}
+
+	number, err := strconv.Atoi(c.Param("rev"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid revision number")
+		return
+	}
+
+	var note models.Note
+	if err := database.DB.Where("id = ? AND user_id = ?", noteID, userID).First(&note).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Note not found")
+		return
+	}
+
+	var revision models.NoteRevision
+	if err := database.DB.Where("note_id = ? AND number = ?", note.ID, number).First(&revision).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Revision not found")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, revision)
+}
+
+// DiffRevisions compares revisions from and to of a note. to defaults to the
+// latest revision and from to the one before to. mode is unified, the
+// default, or word.
+func (h *NoteHandler) DiffRevisions(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	noteID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid note ID")
+		return
+	}
+
+	mode := c.DefaultQuery("mode", models.DiffModeUnified)
+	if mode != models.DiffModeUnified && mode != models.DiffModeWord {
+		utils.ErrorResponse(c, http.StatusBadRequest, "mode must be unified or word")
+		return
+	}
+
+	var note models.Note
+	if err := database.DB.Where("id = ? AND user_id = ?", noteID, userID).First(&note).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Note not found")
+		return
+	}
+
+	var to models.NoteRevision
+	toQuery := database.DB.Where("note_id = ?", note.ID)
+	if param := c.Query("to"); param != "" {
+		number, err := strconv.Atoi(param)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid to revision number")
+			return
+		}
+		toQuery = toQuery.Where("number = ?", number)
+	}
+	if err := toQuery.Order("number DESC").First(&to).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Revision not found")
+		return
+	}
+
+	var from models.NoteRevision
+	fromQuery := database.DB.Where("note_id = ?", note.ID)
+	if param := c.Query("from"); param != "" {
+		number, err := strconv.Atoi(param)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid from revision number")
+			return
+		}
+		fromQuery = fromQuery.Where("number = ?", number)
+	} else {
+		fromQuery = fromQuery.Where("number < ?", to.Number)
+	}
+	if err := fromQuery.Order("number DESC").First(&from).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Revision not found")
+		return
+	}
+
+	diff := models.NoteRevisionDiff{
+		From:         from.Number,
+		To:           to.Number,
+		Mode:         mode,
+		Title:        services.DiffWords(from.Title, to.Title),
+		LinksAdded:   missingLinks(to.Links, from.Links),
+		LinksRemoved: missingLinks(from.Links, to.Links),
+	}
+	if mode == models.DiffModeWord {
+		diff.Content = services.DiffWords(from.Content, to.Content)
+	} else {
+		diff.Unified = services.UnifiedDiff(from.Content, to.Content,
+			fmt.Sprintf("revision %d", from.Number), fmt.Sprintf("revision %d", to.Number))
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, diff)
+}
+
+// RestoreRevision puts a note back the way it was in a revision. Links made
+// through the API are restored when the linked item is still cached and the
+// user can still read it; links detected in the content come back from the
+// restored content. The restore is recorded as a new revision, and nothing is
+// changed unless all of it succeeds.
+func (h *NoteHandler) RestoreRevision(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	noteID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid note ID")
+		return
+	}
+
+	number, err := strconv.Atoi(c.Param("rev"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid revision number")
+		return
+	}
+
+	var note models.Note
+	if err := database.DB.Where("id = ? AND user_id = ?", noteID, userID).First(&note).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Note not found")
+		return
+	}
+
+	var revision models.NoteRevision
+	if err := database.DB.Where("note_id = ? AND number = ?", note.ID, number).First(&revision).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Revision not found")
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "User not found")
+		return
+	}
+
+	// Reading the restored links may call the code host, so it is done
+	// before the restore transaction is opened
+	note.Title = revision.Title
+	note.Content = revision.Content
+	relinks, err := h.readableRevisionLinks(revision.Links, &user)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to restore note")
+		return
+	}
+	detected := h.detectLinks(&note, &user)
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&note).Updates(map[string]interface{}{
+			"title":   note.Title,
+			"content": note.Content,
+		}).Error; err != nil {
+			return err
+		}
+
+		if err := clearReferences(tx, &note); err != nil {
+			return err
+		}
+		if err := relinkRevision(tx, &note, relinks); err != nil {
+			return err
+		}
+		if err := applyDetectedLinks(tx, &note, detected); err != nil {
+			return err
+		}
+		if err := pruneSnapshots(tx, &note); err != nil {
+			return err
+		}
+
+		// Re-point the note at the repository of its restored links
+		if err := tx.Scopes(withNoteLinks).First(&note, note.ID).Error; err != nil {
+			return err
+		}
+		note.RepositoryID = noteRepositoryFromLinks(tx, &note)
+		if err := tx.Model(&note).Update("repository_id", note.RepositoryID).Error; err != nil {
+			return err
+		}
+
+		return h.recordRevision(tx, &note, userID)
+	})
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to restore note")
+		return
+	}
+
+	h.respondWithNote(c, &note, &user)
+}
+
+// recordRevision saves the note's title, content and links as its next
+// revision, unless they match the latest one, then drops the revisions the
+// retention settings no longer keep. db must be the transaction that changed
+// the note: the note row is locked until it commits, so concurrent saves
+// number their revisions one after the other.
+func (h *NoteHandler) recordRevision(db *gorm.DB, note *models.Note, editorID uuid.UUID) error {
+	var locked models.Note
+	if err := db.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&locked, note.ID).Error; err != nil {
+		return err
+	}
+
+	links, err := revisionLinks(db, note.ID)
+	if err != nil {
+		return err
+	}
+
+	var latest models.NoteRevision
+	err = db.Where("note_id = ?", note.ID).Order("number DESC").First(&latest).Error
+	if err == nil {
+		if latest.Title == note.Title && latest.Content == note.Content &&
+			len(missingLinks(links, latest.Links)) == 0 && len(missingLinks(latest.Links, links)) == 0 {
+			return nil
+		}
+	} else if err != gorm.ErrRecordNotFound {
+		return err
+	}
+
+	revision := models.NoteRevision{
+		ID:       uuid.New(),
+		NoteID:   note.ID,
+		Number:   latest.Number + 1,
+		Title:    note.Title,
+		Content:  note.Content,
+		Links:    links,
+		EditorID: editorID,
+	}
+	if err := db.Create(&revision).Error; err != nil {
+		return err
+	}
+
+	return h.pruneRevisions(db, note.ID, revision.Number)
+}
+
+// pruneRevisions deletes the note's revisions that are neither among the
+// last revisionKeepLast nor younger than revisionKeepDays. latest is the
+// number of the newest revision.
+func (h *NoteHandler) pruneRevisions(db *gorm.DB, noteID uuid.UUID, latest int) error {
+	if h.revisionKeepLast <= 0 && h.revisionKeepDays <= 0 {
+		return nil
+	}
+
+	query := db.Where("note_id = ?", noteID)
+	if h.revisionKeepLast > 0 {
+		query = query.Where("number <= ?", latest-h.revisionKeepLast)
+	}
+	if h.revisionKeepDays > 0 {
+		query = query.Where("created_at < ?", time.Now().AddDate(0, 0, -h.revisionKeepDays))
+	}
+	return query.Delete(&models.NoteRevision{}).Error
+}
+
+// revisionLinks lists what the note is linked to now.
+func revisionLinks(db *gorm.DB, noteID uuid.UUID) ([]models.RevisionLink, error) {
+	links := []models.RevisionLink{}
+
+	var prLinks []models.NotePRLink
+	if err := db.Where("note_id = ?", noteID).Find(&prLinks).Error; err != nil {
+		return nil, err
+	}
+	if len(prLinks) > 0 {
+		detected := make(map[uuid.UUID]bool, len(prLinks))
+		ids := make([]uuid.UUID, len(prLinks))
+		for i, link := range prLinks {
+			detected[link.PRID] = link.Detected
+			ids[i] = link.PRID
+		}
+		var prs []models.PullRequest
+		if err := db.Where("id IN ?", ids).Find(&prs).Error; err != nil {
+			return nil, err
+		}
+		for _, pr := range prs {
+			links = append(links, models.RevisionLink{
+				Kind:     models.RefPullRequest,
+				ID:       pr.ID,
+				Ref:      fmt.Sprintf("%s/%s#%d", pr.RepoOwner, pr.RepoName, pr.Number),
+				URL:      pr.URL,
+				Detected: detected[pr.ID],
+			})
+		}
+	}
+
+	var issueLinks []models.NoteIssueLink
+	if err := db.Where("note_id = ?", noteID).Find(&issueLinks).Error; err != nil {
+		return nil, err
+	}
+	if len(issueLinks) > 0 {
+		detected := make(map[uuid.UUID]bool, len(issueLinks))
+		ids := make([]uuid.UUID, len(issueLinks))
+		for i, link := range issueLinks {
+			detected[link.IssueID] = link.Detected
+			ids[i] = link.IssueID
+		}
+		var issues []models.Issue
+		if err := db.Where("id IN ?", ids).Find(&issues).Error; err != nil {
+			return nil, err
+		}
+		for _, issue := range issues {
+			links = append(links, models.RevisionLink{
+				Kind:     models.RefIssue,
+				ID:       issue.ID,
+				Ref:      fmt.Sprintf("%s/%s#%d", issue.RepoOwner, issue.RepoName, issue.Number),
+				URL:      issue.URL,
+				Detected: detected[issue.ID],
+			})
+		}
+	}
+
+	var commits []models.Commit
+	if err := db.Joins("JOIN note_commit_links ON note_commit_links.commit_id = commits.id").
+		Where("note_commit_links.note_id = ?", noteID).
+		Find(&commits).Error; err != nil {
+		return nil, err
+	}
+	for _, commit := range commits {
+		links = append(links, models.RevisionLink{
+			Kind: models.RefCommit,
+			ID:   commit.ID,
+			Ref:  fmt.Sprintf("%s/%s@%s", commit.RepoOwner, commit.RepoName, commit.SHA[:min(7, len(commit.SHA))]),
+			URL:  commit.URL,
+		})
+	}
+
+	sort.Slice(links, func(i, j int) bool {
+		if links[i].Kind != links[j].Kind {
+			return links[i].Kind < links[j].Kind
+		}
+		return links[i].Ref < links[j].Ref
+	})
+	return links, nil
+}
+
+// readableRevisionLinks returns the links a revision made through the API to
+// items that are still cached and that user can read, checked the same way as
+// linking them anew.
+func (h *NoteHandler) readableRevisionLinks(links []models.RevisionLink, user *models.User) ([]models.RevisionLink, error) {
+	readable := []models.RevisionLink{}
+	for _, link := range links {
+		if link.Detected {
+			continue
+		}
+
+		var err error
+		canRead := false
+		switch link.Kind {
+		case models.RefPullRequest:
+			var pr models.PullRequest
+			if err = database.DB.First(&pr, link.ID).Error; err == nil {
+				canRead = h.access.CanRead(user, &pr)
+			}
+		case models.RefIssue:
+			var issue models.Issue
+			if err = database.DB.First(&issue, link.ID).Error; err == nil {
+				canRead = h.access.CanReadIssue(user, &issue)
+			}
+		case models.RefCommit:
+			var commit models.Commit
+			if err = database.DB.First(&commit, link.ID).Error; err == nil {
+				canRead = h.access.CanReadCommit(user, &commit)
+			}
+		}
+		if err != nil && err != gorm.ErrRecordNotFound {
+			return nil, err
+		}
+		if canRead {
+			readable = append(readable, link)
+		}
+	}
+	return readable, nil
+}
+
+// relinkRevision links the note again to the items of links, as returned by
+// readableRevisionLinks.
+func relinkRevision(db *gorm.DB, note *models.Note, links []models.RevisionLink) error {
+	for _, link := range links {
+		var row interface{}
+		switch link.Kind {
+		case models.RefPullRequest:
+			row = &models.NotePRLink{NoteID: note.ID, PRID: link.ID}
+		case models.RefIssue:
+			row = &models.NoteIssueLink{NoteID: note.ID, IssueID: link.ID}
+		case models.RefCommit:
+			row = &models.NoteCommitLink{NoteID: note.ID, CommitID: link.ID}
+		default:
+			continue
+		}
+		if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(row).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// missingLinks returns the links in a that b does not have.
+func missingLinks(a, b []models.RevisionLink) []models.RevisionLink {
+	missing := []models.RevisionLink{}
+	for _, link := range a {
+		found := false
+		for _, other := range b {
+			if other.Kind == link.Kind && other.ID == link.ID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, link)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github-notes-backend/internal/database"
+	"github-notes-backend/internal/middleware"
+	"github-notes-backend/internal/models"
+	"github-notes-backend/internal/services"
+	"github-notes-backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ListTrash lists the caller's trashed notes, most recently trashed first.
+func (h *NoteHandler) ListTrash(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "User not found")
+		return
+	}
+
+	page, limit, offset := utils.GetPaginationParams(c)
+
+	query := database.DB.Unscoped().Where("user_id = ? AND deleted_at IS NOT NULL", userID)
+
+	var total int64
+	query.Model(&models.Note{}).Count(&total)
+
+	var notes []models.Note
+	if err := query.Scopes(withNoteLinks).
+		Offset(offset).
+		Limit(limit).
+		Order("deleted_at DESC").
+		Find(&notes).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch trash")
+		return
+	}
+
+	restrictUnreadableLinks(h.access, notes, &user)
+
+	trashed := make([]models.TrashedNote, len(notes))
+	for i, note := range notes {
+		trashed[i].Note = note
+		if h.trashRetention > 0 {
+			purgeAt := note.DeletedAt.Time.Add(h.trashRetention)
+			trashed[i].PurgeAt = &purgeAt
+		}
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, models.TrashResponse{
+		Notes: trashed,
+		Total: total,
+		Page:  page,
+		Limit: limit,
+	})
+}
+
+// RestoreNote takes a note out of the trash with its links and tags. A note
+// whose notebook was deleted in the meantime is restored outside any
+// notebook.
+func (h *NoteHandler) RestoreNote(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	noteID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid note ID")
+		return
+	}
+
+	var note models.Note
+	if err := database.DB.Unscoped().
+		Where("id = ? AND user_id = ? AND deleted_at IS NOT NULL", noteID, userID).
+		First(&note).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Note not found in trash")
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "User not found")
+		return
+	}
+
+	if note.NotebookID != nil {
+		var notebook models.Notebook
+		if err := database.DB.Where("id = ? AND user_id = ?", *note.NotebookID, userID).First(&notebook).Error; err != nil {
+			note.NotebookID = nil
+		}
+	}
+
+	if err := database.DB.Unscoped().Model(&note).Updates(map[string]interface{}{
+		"deleted_at":  nil,
+		"notebook_id": note.NotebookID,
+	}).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to restore note")
+		return
+	}
+
+	if err := database.DB.Scopes(withNoteLinks).First(&note, note.ID).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch note")
+		return
+	}
+	h.respondWithNote(c, &note, &user)
+}
+
+// PurgeNote deletes a trashed note for good.
+func (h *NoteHandler) PurgeNote(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	noteID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid note ID")
+		return
+	}
+
+	var note models.Note
+	if err := database.DB.Unscoped().
+		Where("id = ? AND user_id = ? AND deleted_at IS NOT NULL", noteID, userID).
+		First(&note).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Note not found in trash")
+		return
+	}
+
+	if err := services.PurgeNotes([]uuid.UUID{note.ID}); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete note")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, gin.H{"message": "Note deleted permanently"})
+}
+
+// EmptyTrash deletes every trashed note of the caller for good.
+func (h *NoteHandler) EmptyTrash(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	var ids []uuid.UUID
+	if err := database.DB.Unscoped().Model(&models.Note{}).
+		Where("user_id = ? AND deleted_at IS NOT NULL", userID).
+		Pluck("id", &ids).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch trash")
+		return
+	}
+
+	if err := services.PurgeNotes(ids); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to empty trash")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, gin.H{"message": "Trash emptied", "deleted": len(ids)})
+}
@@ -0,0 +1,448 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github-notes-backend/internal/database"
+	"github-notes-backend/internal/middleware"
+	"github-notes-backend/internal/models"
+	"github-notes-backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type NotebookHandler struct{}
+
+func NewNotebookHandler() *NotebookHandler {
+	return &NotebookHandler{}
+}
+
+// ListNotebooks returns the caller's notebooks as a tree, each with its note
+// counts.
+func (h *NotebookHandler) ListNotebooks(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	notebooks, err := loadNotebooks(userID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch notebooks")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, notebookTree(notebooks, notebookNoteCounts(userID), nil))
+}
+
+// GetNotebook returns the subtree rooted at a notebook.
+func (h *NotebookHandler) GetNotebook(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	notebookID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid notebook ID")
+		return
+	}
+
+	notebooks, err := loadNotebooks(userID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch notebooks")
+		return
+	}
+
+	for _, notebook := range notebooks {
+		if notebook.ID == notebookID {
+			utils.SuccessResponse(c, http.StatusOK, notebookTreeItem(notebook, notebooks, notebookNoteCounts(userID)))
+			return
+		}
+	}
+	utils.ErrorResponse(c, http.StatusNotFound, "Notebook not found")
+}
+
+func (h *NotebookHandler) CreateNotebook(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	var req models.CreateNotebookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if req.ParentID != nil {
+		var parent models.Notebook
+		if err := database.DB.Where("id = ? AND user_id = ?", *req.ParentID, userID).First(&parent).Error; err != nil {
+			utils.ErrorResponse(c, http.StatusNotFound, "Parent notebook not found")
+			return
+		}
+	}
+
+	notebook := models.Notebook{
+		ID:       uuid.New(),
+		UserID:   userID,
+		ParentID: req.ParentID,
+		Name:     req.Name,
+	}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&notebook).Error; err != nil {
+			return err
+		}
+		return placeNotebook(tx, &notebook, req.Position)
+	})
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to create notebook")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, models.NotebookTreeItem{Notebook: notebook, Children: []models.NotebookTreeItem{}})
+}
+
+// UpdateNotebook renames a notebook.
+func (h *NotebookHandler) UpdateNotebook(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	notebookID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid notebook ID")
+		return
+	}
+
+	var req models.UpdateNotebookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var notebook models.Notebook
+	if err := database.DB.Where("id = ? AND user_id = ?", notebookID, userID).First(&notebook).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Notebook not found")
+		return
+	}
+
+	notebook.Name = req.Name
+	if err := database.DB.Save(&notebook).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update notebook")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, notebook)
+}
+
+// MoveNotebook moves a notebook under another parent and/or to another
+// position among its siblings. A notebook cannot be moved into its own
+// subtree.
+func (h *NotebookHandler) MoveNotebook(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	notebookID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid notebook ID")
+		return
+	}
+
+	var req models.MoveNotebookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	notebooks, err := loadNotebooks(userID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch notebooks")
+		return
+	}
+
+	var notebook *models.Notebook
+	var parentFound bool
+	for i := range notebooks {
+		if notebooks[i].ID == notebookID {
+			notebook = &notebooks[i]
+		}
+		if req.ParentID != nil && notebooks[i].ID == *req.ParentID {
+			parentFound = true
+		}
+	}
+	if notebook == nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Notebook not found")
+		return
+	}
+	if req.ParentID != nil {
+		if !parentFound {
+			utils.ErrorResponse(c, http.StatusNotFound, "Parent notebook not found")
+			return
+		}
+		for _, id := range notebookSubtreeIDs(notebooks, notebook.ID) {
+			if id == *req.ParentID {
+				utils.ErrorResponse(c, http.StatusBadRequest, "A notebook cannot be moved into itself or one of its sub-notebooks")
+				return
+			}
+		}
+	}
+
+	oldParentID := notebook.ParentID
+	notebook.ParentID = req.ParentID
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := placeNotebook(tx, notebook, req.Position); err != nil {
+			return err
+		}
+		// Close the gap left among the old siblings
+		return renumberNotebooks(tx, userID, oldParentID)
+	})
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to move notebook")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, notebook)
+}
+
+// DeleteNotebook deletes a notebook. With mode=cascade its sub-notebooks are
+// deleted too and its notes moved to the trash; with mode=move_up, the
+// default, they move to the notebook's parent, or to the top level.
+func (h *NotebookHandler) DeleteNotebook(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	notebookID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid notebook ID")
+		return
+	}
+
+	mode := c.DefaultQuery("mode", models.NotebookDeleteMoveUp)
+	if mode != models.NotebookDeleteCascade && mode != models.NotebookDeleteMoveUp {
+		utils.ErrorResponse(c, http.StatusBadRequest, "mode must be cascade or move_up")
+		return
+	}
+
+	notebooks, err := loadNotebooks(userID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch notebooks")
+		return
+	}
+
+	var notebook *models.Notebook
+	for i := range notebooks {
+		if notebooks[i].ID == notebookID {
+			notebook = &notebooks[i]
+		}
+	}
+	if notebook == nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Notebook not found")
+		return
+	}
+
+	if mode == models.NotebookDeleteCascade {
+		err = deleteNotebookSubtree(notebook, notebookSubtreeIDs(notebooks, notebook.ID))
+	} else {
+		err = deleteNotebookMovingUp(notebook)
+	}
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete notebook")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, gin.H{"message": "Notebook deleted successfully"})
+}
+
+// deleteNotebookSubtree deletes the notebook and the notebooks below it
+// (ids), moving every note filed in them to the trash.
+func deleteNotebookSubtree(notebook *models.Notebook, ids []uuid.UUID) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND notebook_id IN ?", notebook.UserID, ids).Delete(&models.Note{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ? AND id IN ?", notebook.UserID, ids).Delete(&models.Notebook{}).Error; err != nil {
+			return err
+		}
+		return renumberNotebooks(tx, notebook.UserID, notebook.ParentID)
+	})
+}
+
+// deleteNotebookMovingUp hands the notebook's notes and sub-notebooks to its
+// parent before deleting it. The sub-notebooks go after the parent's other
+// children, keeping their order.
+func deleteNotebookMovingUp(notebook *models.Notebook) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		// Trashed notes move too, so restoring them finds a notebook
+		if err := tx.Unscoped().Model(&models.Note{}).
+			Where("user_id = ? AND notebook_id = ?", notebook.UserID, notebook.ID).
+			Update("notebook_id", notebook.ParentID).Error; err != nil {
+			return err
+		}
+
+		// Offset the children past the existing siblings so renumbering
+		// keeps them last
+		if err := tx.Model(&models.Notebook{}).
+			Where("user_id = ? AND parent_id = ?", notebook.UserID, notebook.ID).
+			Updates(map[string]interface{}{
+				"parent_id": notebook.ParentID,
+				"position":  gorm.Expr("position + ?", maxNotebookPosition),
+			}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Delete(notebook).Error; err != nil {
+			return err
+		}
+		return renumberNotebooks(tx, notebook.UserID, notebook.ParentID)
+	})
+}
+
+// maxNotebookPosition is larger than any position renumbering hands out.
+const maxNotebookPosition = 1 << 20
+
+// loadNotebooks returns all of the user's notebooks in sibling order.
+func loadNotebooks(userID uuid.UUID) ([]models.Notebook, error) {
+	var notebooks []models.Notebook
+	err := database.DB.Where("user_id = ?", userID).Order("position, created_at").Find(&notebooks).Error
+	return notebooks, err
+}
+
+// notebookNoteCounts counts the user's notes filed in each notebook.
+func notebookNoteCounts(userID uuid.UUID) map[uuid.UUID]int64 {
+	var counts []struct {
+		NotebookID uuid.UUID
+		Count      int64
+	}
+	database.DB.Model(&models.Note{}).
+		Select("notebook_id, COUNT(*) AS count").
+		Where("user_id = ? AND notebook_id IS NOT NULL", userID).
+		Group("notebook_id").
+		Scan(&counts)
+
+	byID := make(map[uuid.UUID]int64, len(counts))
+	for _, count := range counts {
+		byID[count.NotebookID] = count.Count
+	}
+	return byID
+}
+
+// notebookTree builds the trees of the notebooks under parentID, or of the
+// top level notebooks when it is nil.
+func notebookTree(notebooks []models.Notebook, counts map[uuid.UUID]int64, parentID *uuid.UUID) []models.NotebookTreeItem {
+	items := []models.NotebookTreeItem{}
+	for _, notebook := range notebooks {
+		if sameNotebook(notebook.ParentID, parentID) {
+			items = append(items, notebookTreeItem(notebook, notebooks, counts))
+		}
+	}
+	return items
+}
+
+func notebookTreeItem(notebook models.Notebook, notebooks []models.Notebook, counts map[uuid.UUID]int64) models.NotebookTreeItem {
+	item := models.NotebookTreeItem{
+		Notebook:  notebook,
+		NoteCount: counts[notebook.ID],
+		Children:  notebookTree(notebooks, counts, &notebook.ID),
+	}
+	item.TotalNoteCount = item.NoteCount
+	for _, child := range item.Children {
+		item.TotalNoteCount += child.TotalNoteCount
+	}
+	return item
+}
+
+// notebookSubtreeIDs returns the id of the notebook and of all notebooks
+// below it.
+func notebookSubtreeIDs(notebooks []models.Notebook, id uuid.UUID) []uuid.UUID {
+	ids := []uuid.UUID{id}
+	for i := 0; i < len(ids); i++ {
+		for _, notebook := range notebooks {
+			if notebook.ParentID != nil && *notebook.ParentID == ids[i] {
+				ids = append(ids, notebook.ID)
+			}
+		}
+	}
+	return ids
+}
+
+func sameNotebook(a, b *uuid.UUID) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+// placeNotebook saves the notebook under its ParentID at position among its
+// siblings, or after them when position is nil, and renumbers the siblings.
+func placeNotebook(tx *gorm.DB, notebook *models.Notebook, position *int) error {
+	siblings, err := siblingNotebooks(tx, notebook.UserID, notebook.ParentID)
+	if err != nil {
+		return err
+	}
+
+	ordered := make([]models.Notebook, 0, len(siblings)+1)
+	for _, sibling := range siblings {
+		if sibling.ID != notebook.ID {
+			ordered = append(ordered, sibling)
+		}
+	}
+	at := len(ordered)
+	if position != nil && *position < at {
+		at = *position
+	}
+	ordered = append(ordered[:at], append([]models.Notebook{*notebook}, ordered[at:]...)...)
+
+	if err := tx.Model(notebook).Update("parent_id", notebook.ParentID).Error; err != nil {
+		return err
+	}
+	notebook.Position = at
+	return savePositions(tx, ordered)
+}
+
+// renumberNotebooks closes gaps in the positions of the children of parentID.
+func renumberNotebooks(tx *gorm.DB, userID uuid.UUID, parentID *uuid.UUID) error {
+	siblings, err := siblingNotebooks(tx, userID, parentID)
+	if err != nil {
+		return err
+	}
+	return savePositions(tx, siblings)
+}
+
+func siblingNotebooks(tx *gorm.DB, userID uuid.UUID, parentID *uuid.UUID) ([]models.Notebook, error) {
+	query := tx.Where("user_id = ?", userID)
+	if parentID != nil {
+		query = query.Where("parent_id = ?", *parentID)
+	} else {
+		query = query.Where("parent_id IS NULL")
+	}
+
+	var siblings []models.Notebook
+	err := query.Order("position, created_at").Find(&siblings).Error
+	return siblings, err
+}
+
+// savePositions numbers the notebooks from 0 in the given order.
+func savePositions(tx *gorm.DB, notebooks []models.Notebook) error {
+	for i, notebook := range notebooks {
+		if notebook.Position == i {
+			continue
+		}
+		if err := tx.Model(&models.Notebook{}).Where("id = ?", notebook.ID).Update("position", i).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github-notes-backend/internal/models"
+	"github-notes-backend/internal/services"
+)
+
+// providerForUser wraps services.ProviderForUser, turning missing credentials
+// into a 400 response.
+func providerForUser(github *services.GitHubService, user *models.User, provider, host string) (*services.ProviderClient, error) {
+	client, err := services.ProviderForUser(github, user, provider, host)
+	if err != nil {
+		if _, ok := err.(*services.CredentialError); ok {
+			return nil, &apiError{http.StatusBadRequest, err.Error()}
+		}
+		return nil, &apiError{http.StatusInternalServerError, "Failed to load credentials"}
+	}
+	return client, nil
+}
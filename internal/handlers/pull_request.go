@@ -0,0 +1,359 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github-notes-backend/internal/database"
+	"github-notes-backend/internal/middleware"
+	"github-notes-backend/internal/models"
+	"github-notes-backend/internal/services"
+	"github-notes-backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type PullRequestHandler struct {
+	githubService *services.GitHubService
+	refresher     *services.PRRefresher
+	access        *services.AccessChecker
+}
+
+func NewPullRequestHandler(refresher *services.PRRefresher, access *services.AccessChecker) *PullRequestHandler {
+	return &PullRequestHandler{
+		githubService: services.NewGitHubService(),
+		refresher:     refresher,
+		access:        access,
+	}
+}
+
+// RefreshAll re-fetches every pull request linked to the caller's notes,
+// regardless of how fresh the cached copies are.
+func (h *PullRequestHandler) RefreshAll(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "User not found")
+		return
+	}
+
+	var prs []models.PullRequest
+	if err := database.DB.Distinct("pull_requests.*").
+		Joins("JOIN note_pr_links ON note_pr_links.pr_id = pull_requests.id").
+		Joins("JOIN notes ON notes.id = note_pr_links.note_id").
+		Where("notes.user_id = ? AND notes.deleted_at IS NULL", userID).
+		Find(&prs).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch pull requests")
+		return
+	}
+
+	all := make([]*models.PullRequest, len(prs))
+	for i := range prs {
+		all[i] = &prs[i]
+	}
+	unreadable := h.access.Unreadable(&user, all)
+
+	var refs []*models.PullRequest
+	var skipped []services.RefreshFailure
+	for _, pr := range all {
+		if unreadable[pr.ID] {
+			skipped = append(skipped, services.RefreshFailure{
+				PullRequest: services.PRRefFor(pr).String(),
+				Error:       errPullRequestNotReadable.Error(),
+			})
+			continue
+		}
+		refs = append(refs, pr)
+	}
+
+	result := h.refresher.Refresh(refs, &user, models.EventSourceManual)
+	result.Failed = append(result.Failed, skipped...)
+
+	utils.SuccessResponse(c, http.StatusOK, result)
+}
+
+// RefreshPullRequest re-fetches a single PR right away, ignoring its cache
+// TTL, and returns the fields that changed.
+func (h *PullRequestHandler) RefreshPullRequest(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	prID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid pull request ID")
+		return
+	}
+
+	pr, err := findUserPullRequest(prID, userID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Pull request not found")
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "User not found")
+		return
+	}
+
+	if !h.access.CanRead(&user, pr) {
+		respondError(c, errPullRequestNotReadable)
+		return
+	}
+
+	if !allowManualRefresh(c, h.refresher, userID) {
+		return
+	}
+
+	changes, err := h.refresher.RefreshOne(pr, &user, models.EventSourceManual)
+	if err != nil {
+		if _, ok := err.(*services.CredentialError); ok {
+			utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		utils.ErrorResponse(c, http.StatusBadGateway, "Failed to refresh pull request: "+err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, models.RefreshPullRequestResponse{
+		PullRequest: *pr,
+		Changes:     changes,
+	})
+}
+
+// allowManualRefresh enforces the per-user manual refresh cooldown, answering
+// 429 with Retry-After when the user has to wait.
+func allowManualRefresh(c *gin.Context, refresher *services.PRRefresher, userID uuid.UUID) bool {
+	wait, ok := refresher.AllowManualRefresh(userID)
+	if ok {
+		return true
+	}
+
+	seconds := int(math.Ceil(wait.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	utils.ErrorResponse(c, http.StatusTooManyRequests, fmt.Sprintf("Please wait %d seconds before refreshing again", seconds))
+	return false
+}
+
+// ListPullRequests lists the cached PRs linked to the caller's notes, most
+// recently updated first, with the number of the caller's notes on each.
+// Filter with repo=owner/name, state= and author=.
+func (h *PullRequestHandler) ListPullRequests(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "User not found")
+		return
+	}
+
+	page, limit, offset := utils.GetPaginationParams(c)
+
+	query := database.DB.Model(&models.PullRequest{}).
+		Where("EXISTS (SELECT 1 FROM note_pr_links JOIN notes ON notes.id = note_pr_links.note_id WHERE note_pr_links.pr_id = pull_requests.id AND notes.user_id = ? AND notes.deleted_at IS NULL)", userID)
+
+	if repo := c.Query("repo"); repo != "" {
+		// GitLab namespaces may contain slashes; the project name never does
+		slash := strings.LastIndex(repo, "/")
+		if slash <= 0 || slash == len(repo)-1 {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid repo, expected owner/name")
+			return
+		}
+		query = query.Where("repo_owner = ? AND repo_name = ?",
+			models.NormalizeIdentifier(repo[:slash]), models.NormalizeIdentifier(repo[slash+1:]))
+	}
+	if state := c.Query("state"); state != "" {
+		query = query.Where("state = ?", state)
+	}
+	if author := c.Query("author"); author != "" {
+		query = query.Where("LOWER(author) = LOWER(?)", author)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var prs []models.PullRequest
+	if err := query.Order("updated_at DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&prs).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch pull requests")
+		return
+	}
+
+	items := make([]models.PullRequestListItem, len(prs))
+	ids := make([]uuid.UUID, len(prs))
+	for i := range prs {
+		items[i].PullRequest = prs[i]
+		ids[i] = prs[i].ID
+	}
+
+	if len(ids) > 0 {
+		var counts []struct {
+			PRID  uuid.UUID
+			Count int64
+		}
+		if err := database.DB.Table("note_pr_links").
+			Select("note_pr_links.pr_id AS pr_id, COUNT(*) AS count").
+			Joins("JOIN notes ON notes.id = note_pr_links.note_id").
+			Where("notes.user_id = ? AND notes.deleted_at IS NULL AND note_pr_links.pr_id IN ?", userID, ids).
+			Group("note_pr_links.pr_id").
+			Scan(&counts).Error; err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to count notes")
+			return
+		}
+
+		countByID := make(map[uuid.UUID]int64, len(counts))
+		for _, count := range counts {
+			countByID[count.PRID] = count.Count
+		}
+
+		refs := make([]*models.PullRequest, len(items))
+		for i := range items {
+			items[i].NoteCount = countByID[items[i].ID]
+			refs[i] = &items[i].PullRequest
+		}
+		h.present(refs, &user)
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, models.PullRequestsResponse{
+		PullRequests: items,
+		Total:        total,
+		Page:         page,
+		Limit:        limit,
+	})
+}
+
+// GetPullRequest returns a cached PR with every note the caller wrote about it.
+func (h *PullRequestHandler) GetPullRequest(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	prID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid pull request ID")
+		return
+	}
+
+	var pr models.PullRequest
+	if err := database.DB.First(&pr, prID).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Pull request not found")
+		return
+	}
+
+	h.respondWithNotes(c, &pr, userID)
+}
+
+// GetPullRequestByRef looks a cached PR up by repository and number and
+// returns it with the caller's notes. GitHub is assumed unless provider= and
+// host= name another code host.
+func (h *PullRequestHandler) GetPullRequestByRef(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	number, err := strconv.Atoi(c.Param("number"))
+	if err != nil || number <= 0 {
+		utils.ErrorResponse(c, http.StatusBadRequest, "PR number must be greater than 0")
+		return
+	}
+
+	provider := services.NormalizeProvider(c.Query("provider"))
+	host := c.Query("host")
+	if provider == models.ProviderGitHub && host == "" {
+		host = models.DefaultGitHubHost
+	}
+	if host == "" {
+		utils.ErrorResponse(c, http.StatusBadRequest, "host is required for "+provider)
+		return
+	}
+
+	var pr models.PullRequest
+	if err := database.DB.Where("provider = ? AND host = ? AND repo_owner = ? AND repo_name = ? AND number = ?",
+		provider, models.NormalizeIdentifier(host), models.NormalizeIdentifier(c.Param("owner")),
+		models.NormalizeIdentifier(c.Param("repo")), number).First(&pr).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Pull request not found")
+		return
+	}
+
+	h.respondWithNotes(c, &pr, userID)
+}
+
+// respondWithNotes answers with pr, refreshed when stale, and the caller's
+// notes linked to it. PRs the caller cannot read are reported as not found.
+func (h *PullRequestHandler) respondWithNotes(c *gin.Context, pr *models.PullRequest, userID uuid.UUID) {
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "User not found")
+		return
+	}
+
+	if !h.access.CanRead(&user, pr) {
+		respondError(c, errPullRequestNotReadable)
+		return
+	}
+	if h.refresher.IsStale(pr) {
+		h.refresher.Refresh([]*models.PullRequest{pr}, &user, models.EventSourcePoll)
+		pr.SourceUnavailable = pr.Unavailable()
+	}
+
+	var notes []models.Note
+	if err := database.DB.Where("notes.user_id = ?", userID).
+		Where("EXISTS (SELECT 1 FROM note_pr_links WHERE note_pr_links.note_id = notes.id AND note_pr_links.pr_id = ?)", pr.ID).
+		Scopes(withNoteLinks).
+		Order("created_at DESC").
+		Find(&notes).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch notes")
+		return
+	}
+	presentNotes(h.refresher, h.access, notes, &user)
+
+	utils.SuccessResponse(c, http.StatusOK, models.PullRequestNotesResponse{
+		PullRequest: *pr,
+		Notes:       notes,
+	})
+}
+
+// present restricts the PRs the user cannot read and refreshes the stale ones
+// among the rest, in place.
+func (h *PullRequestHandler) present(prs []*models.PullRequest, user *models.User) {
+	unreadable := h.access.Unreadable(user, prs)
+
+	var stale []*models.PullRequest
+	for _, pr := range prs {
+		if unreadable[pr.ID] {
+			pr.Restrict()
+		} else if h.refresher.IsStale(pr) {
+			stale = append(stale, pr)
+		}
+	}
+	if len(stale) == 0 {
+		return
+	}
+
+	h.refresher.Refresh(stale, user, models.EventSourcePoll)
+	for _, pr := range stale {
+		pr.SourceUnavailable = pr.Unavailable()
+	}
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github-notes-backend/internal/database"
+	"github-notes-backend/internal/middleware"
+	"github-notes-backend/internal/models"
+	"github-notes-backend/internal/services"
+	"github-notes-backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// GetComments returns the PR conversation, syncing new and edited comments
+// from GitHub since the last sync before answering. When GitHub cannot be
+// reached the cached comments are returned along with the sync error.
+func (h *PullRequestHandler) GetComments(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	prID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid pull request ID")
+		return
+	}
+
+	pr, err := findUserPullRequest(prID, userID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Pull request not found")
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "User not found")
+		return
+	}
+
+	if !h.access.CanRead(&user, pr) {
+		respondError(c, errPullRequestNotReadable)
+		return
+	}
+
+	response := models.PRCommentsResponse{}
+	if !models.IsGitHubDotCom(pr.Provider, pr.Host) {
+		response.SyncError = "Comment syncing is only supported for github.com pull requests"
+	} else if user.GithubToken == "" {
+		response.SyncError = "GitHub token is required to sync comments. Please update your profile first."
+	} else if err := h.syncComments(pr, user.GithubToken); err != nil {
+		response.SyncError = err.Error()
+		services.RecordFetchFailure(pr, err, models.EventSourcePoll)
+	}
+	response.SyncedAt = pr.CommentsSyncedAt
+
+	if err := database.DB.Where("pull_request_id = ?", pr.ID).
+		Order("github_created_at ASC").
+		Find(&response.Comments).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch comments")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, response)
+}
+
+// findUserPullRequest loads a cached PR that is linked to one of the user's notes.
+func findUserPullRequest(prID, userID uuid.UUID) (*models.PullRequest, error) {
+	var pr models.PullRequest
+	err := database.DB.Where("id = ?", prID).
+		Where("EXISTS (SELECT 1 FROM note_pr_links JOIN notes ON notes.id = note_pr_links.note_id WHERE note_pr_links.pr_id = pull_requests.id AND notes.user_id = ? AND notes.deleted_at IS NULL)", userID).
+		First(&pr).Error
+	if err != nil {
+		return nil, err
+	}
+	return &pr, nil
+}
+
+// syncComments fetches issue and review comments updated since the last sync
+// and upserts them by GitHub comment id.
+func (h *PullRequestHandler) syncComments(pr *models.PullRequest, token string) error {
+	startedAt := time.Now()
+
+	issueComments, err := h.githubService.ListIssueComments(pr.RepoOwner, pr.RepoName, pr.Number, pr.CommentsSyncedAt, token)
+	if err != nil {
+		return err
+	}
+
+	reviewComments, err := h.githubService.ListReviewComments(pr.RepoOwner, pr.RepoName, pr.Number, pr.CommentsSyncedAt, token)
+	if err != nil {
+		return err
+	}
+
+	for _, comment := range issueComments {
+		if err := services.UpsertPRComment(&models.PRComment{
+			PullRequestID:   pr.ID,
+			GithubID:        comment.ID,
+			Kind:            models.PRCommentIssue,
+			Author:          comment.User.Login,
+			Body:            comment.Body,
+			URL:             comment.HTMLURL,
+			GithubCreatedAt: comment.CreatedAt,
+			GithubUpdatedAt: comment.UpdatedAt,
+		}); err != nil {
+			return err
+		}
+	}
+
+	for _, comment := range reviewComments {
+		line := comment.Line
+		if line == nil {
+			// Outdated review comments only keep their original position
+			line = comment.OriginalLine
+		}
+		if err := services.UpsertPRComment(&models.PRComment{
+			PullRequestID:   pr.ID,
+			GithubID:        comment.ID,
+			Kind:            models.PRCommentReview,
+			Author:          comment.User.Login,
+			Body:            comment.Body,
+			Path:            comment.Path,
+			Line:            line,
+			InReplyToID:     comment.InReplyToID,
+			URL:             comment.HTMLURL,
+			GithubCreatedAt: comment.CreatedAt,
+			GithubUpdatedAt: comment.UpdatedAt,
+		}); err != nil {
+			return err
+		}
+	}
+
+	pr.CommentsSyncedAt = &startedAt
+	return database.DB.Model(pr).Update("comments_synced_at", startedAt).Error
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github-notes-backend/internal/database"
+	"github-notes-backend/internal/middleware"
+	"github-notes-backend/internal/models"
+	"github-notes-backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// GetTimeline lists the observed changes to a PR, oldest first. since= (an
+// RFC 3339 time) or note_id= (one of the caller's notes) limits it to what
+// happened after that point; field= limits it to one field.
+func (h *PullRequestHandler) GetTimeline(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	prID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid pull request ID")
+		return
+	}
+
+	var pr models.PullRequest
+	if err := database.DB.First(&pr, prID).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Pull request not found")
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "User not found")
+		return
+	}
+
+	if !h.access.CanRead(&user, &pr) {
+		respondError(c, errPullRequestNotReadable)
+		return
+	}
+
+	page, limit, offset := utils.GetPaginationParams(c)
+
+	query := database.DB.Model(&models.PullRequestEvent{}).Where("pull_request_id = ?", pr.ID)
+
+	if since := c.Query("since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid since, expected an RFC 3339 time")
+			return
+		}
+		query = query.Where("observed_at > ?", t)
+	}
+
+	if noteIDStr := c.Query("note_id"); noteIDStr != "" {
+		noteID, err := uuid.Parse(noteIDStr)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid note ID")
+			return
+		}
+		var note models.Note
+		if err := database.DB.Where("id = ? AND user_id = ?", noteID, userID).First(&note).Error; err != nil {
+			utils.ErrorResponse(c, http.StatusNotFound, "Note not found")
+			return
+		}
+		query = query.Where("observed_at > ?", note.CreatedAt)
+	}
+
+	if field := c.Query("field"); field != "" {
+		query = query.Where("field = ?", field)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var events []models.PullRequestEvent
+	if err := query.Order("observed_at ASC, created_at ASC").
+		Offset(offset).
+		Limit(limit).
+		Find(&events).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch timeline")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, models.PullRequestTimelineResponse{
+		PullRequestID: pr.ID,
+		Events:        events,
+		Total:         total,
+		Page:          page,
+		Limit:         limit,
+	})
+}
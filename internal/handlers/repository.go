@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github-notes-backend/internal/database"
+	"github-notes-backend/internal/middleware"
+	"github-notes-backend/internal/models"
+	"github-notes-backend/internal/services"
+	"github-notes-backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// maxSyncedRepositories caps how many accessible repositories are pulled from
+// GitHub per sync.
+const maxSyncedRepositories = 500
+
+type RepositoryHandler struct {
+	githubService *services.GitHubService
+	refresher     *services.PRRefresher
+	access        *services.AccessChecker
+
+	// syncing holds the ids of users whose repositories are being synced in
+	// the background
+	syncing sync.Map
+}
+
+func NewRepositoryHandler(refresher *services.PRRefresher, access *services.AccessChecker) *RepositoryHandler {
+	return &RepositoryHandler{
+		githubService: services.NewGitHubService(),
+		refresher:     refresher,
+		access:        access,
+	}
+}
+
+// ListRepositories lists the repositories the caller could access on GitHub at
+// the last sync together with every repository that has one of the caller's
+// notes. It never calls GitHub: the first listing starts a sync in the
+// background and POST /repositories/sync refreshes the list.
+func (h *RepositoryHandler) ListRepositories(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "User not found")
+		return
+	}
+
+	page, limit, offset := utils.GetPaginationParams(c)
+	search := strings.ToLower(c.Query("search"))
+
+	response := models.RepositoriesResponse{Page: page, Limit: limit, SyncedAt: user.RepositoriesSyncedAt}
+
+	if user.GithubToken != "" && user.RepositoriesSyncedAt == nil {
+		h.syncInBackground(user)
+	}
+
+	var accessibleIDs []uuid.UUID
+	if err := database.DB.Model(&models.UserRepository{}).
+		Where("user_id = ?", userID).
+		Pluck("repository_id", &accessibleIDs).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch repositories")
+		return
+	}
+	accessible := make(map[uuid.UUID]bool, len(accessibleIDs))
+	for _, id := range accessibleIDs {
+		accessible[id] = true
+	}
+
+	var counts []struct {
+		RepositoryID uuid.UUID
+		Count        int64
+	}
+	if err := database.DB.Model(&models.Note{}).
+		Select("repository_id, COUNT(*) AS count").
+		Where("user_id = ? AND repository_id IS NOT NULL", userID).
+		Group("repository_id").
+		Scan(&counts).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to count notes")
+		return
+	}
+
+	countByID := make(map[uuid.UUID]int64, len(counts))
+	ids := make([]uuid.UUID, 0, len(accessible)+len(counts))
+	for id := range accessible {
+		ids = append(ids, id)
+	}
+	for _, count := range counts {
+		countByID[count.RepositoryID] = count.Count
+		if !accessible[count.RepositoryID] {
+			ids = append(ids, count.RepositoryID)
+		}
+	}
+
+	var repos []models.Repository
+	if len(ids) > 0 {
+		if err := database.DB.Where("id IN ?", ids).Find(&repos).Error; err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch repositories")
+			return
+		}
+	}
+
+	items := make([]models.RepositoryListItem, 0, len(repos))
+	for _, repo := range repos {
+		if search != "" && !strings.Contains(strings.ToLower(repo.FullName()), search) {
+			continue
+		}
+		items = append(items, models.RepositoryListItem{
+			Repository: repo,
+			NoteCount:  countByID[repo.ID],
+			Accessible: accessible[repo.ID],
+		})
+	}
+
+	// Repositories with notes first, then alphabetically
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].NoteCount != items[j].NoteCount {
+			return items[i].NoteCount > items[j].NoteCount
+		}
+		return strings.ToLower(items[i].FullName()) < strings.ToLower(items[j].FullName())
+	})
+
+	response.Total = len(items)
+	if offset > len(items) {
+		offset = len(items)
+	}
+	end := offset + limit
+	if end > len(items) {
+		end = len(items)
+	}
+	response.Repositories = items[offset:end]
+
+	utils.SuccessResponse(c, http.StatusOK, response)
+}
+
+// SyncRepositories pulls the repositories the caller can access from GitHub
+// into the registry.
+func (h *RepositoryHandler) SyncRepositories(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "User not found")
+		return
+	}
+
+	if user.GithubToken == "" {
+		utils.ErrorResponse(c, http.StatusBadRequest, "GitHub token is required to sync repositories. Please update your profile first.")
+		return
+	}
+
+	synced, err := services.SyncUserRepositories(h.githubService, &user, maxSyncedRepositories)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadGateway, "Failed to sync repositories: "+err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, models.RepositorySyncResponse{
+		Synced:   synced,
+		SyncedAt: *user.RepositoriesSyncedAt,
+	})
+}
+
+// syncInBackground syncs the user's repositories unless a background sync for
+// the user is already running.
+func (h *RepositoryHandler) syncInBackground(user models.User) {
+	if _, running := h.syncing.LoadOrStore(user.ID, true); running {
+		return
+	}
+
+	go func() {
+		defer h.syncing.Delete(user.ID)
+		if _, err := services.SyncUserRepositories(h.githubService, &user, maxSyncedRepositories); err != nil {
+			log.Printf("Failed to sync repositories of user %s: %v", user.ID, err)
+		}
+	}()
+}
+
+// GetRepositoryNotes lists the caller's notes in a repository.
+func (h *RepositoryHandler) GetRepositoryNotes(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	repoID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid repository ID")
+		return
+	}
+
+	var repo models.Repository
+	if err := database.DB.First(&repo, repoID).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Repository not found")
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "User not found")
+		return
+	}
+
+	page, limit, offset := utils.GetPaginationParams(c)
+
+	query := database.DB.Where("user_id = ? AND repository_id = ?", userID, repo.ID)
+
+	var total int64
+	query.Model(&models.Note{}).Count(&total)
+
+	var notes []models.Note
+	if err := query.Scopes(withNoteLinks).
+		Offset(offset).
+		Limit(limit).
+		Order("created_at DESC").
+		Find(&notes).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch notes")
+		return
+	}
+
+	presentNotes(h.refresher, h.access, notes, &user)
+
+	utils.SuccessResponse(c, http.StatusOK, models.NotesResponse{
+		Notes: notes,
+		Total: total,
+		Page:  page,
+		Limit: limit,
+	})
+}
@@ -0,0 +1,350 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github-notes-backend/internal/database"
+	"github-notes-backend/internal/middleware"
+	"github-notes-backend/internal/models"
+	"github-notes-backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TagHandler struct{}
+
+func NewTagHandler() *TagHandler {
+	return &TagHandler{}
+}
+
+// ListTags lists the caller's tags by name, each with the number of notes
+// carrying it.
+func (h *TagHandler) ListTags(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	var tags []models.Tag
+	if err := database.DB.Where("user_id = ?", userID).Order("name").Find(&tags).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch tags")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, tagListItems(tags))
+}
+
+func (h *TagHandler) GetTag(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	tagID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid tag ID")
+		return
+	}
+
+	var tag models.Tag
+	if err := database.DB.Where("id = ? AND user_id = ?", tagID, userID).First(&tag).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Tag not found")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, tagListItems([]models.Tag{tag})[0])
+}
+
+func (h *TagHandler) CreateTag(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	var req models.CreateTagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Tag name is required")
+		return
+	}
+	if _, err := findTag(database.DB, userID, name); err == nil {
+		utils.ErrorResponse(c, http.StatusConflict, "A tag with this name already exists")
+		return
+	}
+
+	tag := models.Tag{
+		ID:     uuid.New(),
+		UserID: userID,
+		Name:   name,
+		Color:  req.Color,
+	}
+	if tag.Color == "" {
+		tag.Color = models.DefaultTagColor
+	}
+	if err := database.DB.Create(&tag).Error; err != nil {
+		// Another request created the same name since the check above
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			utils.ErrorResponse(c, http.StatusConflict, "A tag with this name already exists")
+			return
+		}
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to create tag")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, models.TagListItem{Tag: tag})
+}
+
+// UpdateTag renames or recolors a tag. Renaming onto another tag's name is
+// refused; merge the tags instead.
+func (h *TagHandler) UpdateTag(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	tagID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid tag ID")
+		return
+	}
+
+	var req models.UpdateTagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var tag models.Tag
+	if err := database.DB.Where("id = ? AND user_id = ?", tagID, userID).First(&tag).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Tag not found")
+		return
+	}
+
+	if name := strings.TrimSpace(req.Name); name != "" {
+		if other, err := findTag(database.DB, userID, name); err == nil && other.ID != tag.ID {
+			utils.ErrorResponse(c, http.StatusConflict, "A tag with this name already exists. Merge the tags instead.")
+			return
+		}
+		tag.Name = name
+	}
+	if req.Color != "" {
+		tag.Color = req.Color
+	}
+
+	if err := database.DB.Save(&tag).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			utils.ErrorResponse(c, http.StatusConflict, "A tag with this name already exists. Merge the tags instead.")
+			return
+		}
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update tag")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, tagListItems([]models.Tag{tag})[0])
+}
+
+// DeleteTag removes a tag from every note carrying it and deletes it. The
+// notes themselves are kept.
+func (h *TagHandler) DeleteTag(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	tagID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid tag ID")
+		return
+	}
+
+	var tag models.Tag
+	if err := database.DB.Where("id = ? AND user_id = ?", tagID, userID).First(&tag).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Tag not found")
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("tag_id = ?", tag.ID).Delete(&models.NoteTag{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&tag).Error
+	})
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete tag")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, gin.H{"message": "Tag deleted successfully"})
+}
+
+// MergeTag moves every note carrying the tag onto into_id and deletes the
+// tag.
+func (h *TagHandler) MergeTag(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	tagID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid tag ID")
+		return
+	}
+
+	var req models.MergeTagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if req.IntoID == tagID {
+		utils.ErrorResponse(c, http.StatusBadRequest, "A tag cannot be merged into itself")
+		return
+	}
+
+	var from, into models.Tag
+	if err := database.DB.Where("id = ? AND user_id = ?", tagID, userID).First(&from).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Tag not found")
+		return
+	}
+	if err := database.DB.Where("id = ? AND user_id = ?", req.IntoID, userID).First(&into).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Target tag not found")
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`INSERT INTO note_tags (note_id, tag_id)
+			SELECT note_id, ? FROM note_tags WHERE tag_id = ?
+			ON CONFLICT DO NOTHING`, into.ID, from.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("tag_id = ?", from.ID).Delete(&models.NoteTag{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&from).Error
+	})
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to merge tags")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, tagListItems([]models.Tag{into})[0])
+}
+
+// tagListItems pairs each tag with the number of notes carrying it, not
+// counting notes in the trash.
+func tagListItems(tags []models.Tag) []models.TagListItem {
+	items := make([]models.TagListItem, len(tags))
+	ids := make([]uuid.UUID, len(tags))
+	for i := range tags {
+		items[i].Tag = tags[i]
+		ids[i] = tags[i].ID
+	}
+	if len(ids) == 0 {
+		return items
+	}
+
+	var counts []struct {
+		TagID uuid.UUID
+		Count int64
+	}
+	database.DB.Model(&models.NoteTag{}).
+		Select("note_tags.tag_id, COUNT(*) AS count").
+		Joins("JOIN notes ON notes.id = note_tags.note_id AND notes.deleted_at IS NULL").
+		Where("note_tags.tag_id IN ?", ids).
+		Group("note_tags.tag_id").
+		Scan(&counts)
+
+	byID := make(map[uuid.UUID]int64, len(counts))
+	for _, count := range counts {
+		byID[count.TagID] = count.Count
+	}
+	for i := range items {
+		items[i].NoteCount = byID[items[i].ID]
+	}
+	return items
+}
+
+// findTag looks up the user's tag by name, ignoring case.
+func findTag(db *gorm.DB, userID uuid.UUID, name string) (*models.Tag, error) {
+	var tag models.Tag
+	if err := db.Where("user_id = ? AND LOWER(name) = LOWER(?)", userID, name).First(&tag).Error; err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+// findOrCreateTags returns the user's tags with the given names, creating
+// the missing ones with the default color. Names differing only in case
+// name the same tag.
+func findOrCreateTags(db *gorm.DB, userID uuid.UUID, names []string) ([]models.Tag, error) {
+	tags := make([]models.Tag, 0, len(names))
+	seen := make(map[string]bool)
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		key := strings.ToLower(name)
+		if name == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		tag, err := findTag(db, userID, name)
+		if err != nil {
+			// Another request may create it in the meantime; a failed insert
+			// would abort the caller's transaction, so conflicts are skipped
+			// and the tag read again
+			tag = &models.Tag{ID: uuid.New(), UserID: userID, Name: name, Color: models.DefaultTagColor}
+			if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(tag).Error; err != nil {
+				return nil, &apiError{http.StatusInternalServerError, "Failed to create tag"}
+			}
+			if tag, err = findTag(db, userID, name); err != nil {
+				return nil, &apiError{http.StatusInternalServerError, "Failed to create tag"}
+			}
+		}
+		tags = append(tags, *tag)
+	}
+	return tags, nil
+}
+
+// setNoteTags replaces the note's tags with the named ones.
+func setNoteTags(db *gorm.DB, note *models.Note, names []string) error {
+	tags, err := findOrCreateTags(db, note.UserID, names)
+	if err != nil {
+		return err
+	}
+	if err := db.Model(note).Association("Tags").Replace(tags); err != nil {
+		return &apiError{http.StatusInternalServerError, "Failed to tag note"}
+	}
+	return nil
+}
+
+// splitTagNames parses a comma separated tags query into distinct lower case
+// names.
+func splitTagNames(query string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(query, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
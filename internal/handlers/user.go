@@ -1,20 +1,29 @@
 package handlers
 
 import (
+	"log"
 	"net/http"
+	"strings"
 
+	"github-notes-backend/internal/config"
 	"github-notes-backend/internal/database"
 	"github-notes-backend/internal/middleware"
 	"github-notes-backend/internal/models"
+	"github-notes-backend/internal/services"
 	"github-notes-backend/internal/utils"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
-type UserHandler struct{}
+type UserHandler struct {
+	// allowedHosts may be used as credential base URLs although they are
+	// internal or not served over https
+	allowedHosts []string
+}
 
-func NewUserHandler() *UserHandler {
-	return &UserHandler{}
+func NewUserHandler(cfg *config.Config) *UserHandler {
+	return &UserHandler{allowedHosts: cfg.CodeHostAllowedHosts}
 }
 
 func (h *UserHandler) GetProfile(c *gin.Context) {
@@ -75,6 +84,13 @@ func (h *UserHandler) UpdateProfile(c *gin.Context) {
 		return
 	}
 
+	// Private repository access was verified, and the accessible
+	// repositories listed, with the previous token
+	if req.GithubToken != "" {
+		services.ForgetRepositoryAccess(user.ID, models.ProviderGitHub, models.DefaultGitHubHost)
+		services.ForgetUserRepositories(user.ID)
+	}
+
 	// Create response without sensitive data
 	response := models.UserResponse{
 		ID:             user.ID,
@@ -87,3 +103,125 @@ func (h *UserHandler) UpdateProfile(c *gin.Context) {
 
 	utils.SuccessResponse(c, http.StatusOK, response)
 }
+
+// ListCredentials returns the user's code host credentials without tokens.
+func (h *UserHandler) ListCredentials(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	var credentials []models.Credential
+	if err := database.DB.Where("user_id = ?", userID).Order("created_at ASC").Find(&credentials).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch credentials")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, credentials)
+}
+
+// CreateCredential validates a token against its code host and stores it.
+// Registering a token for a host that already has one replaces it.
+func (h *UserHandler) CreateCredential(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	var req models.CreateCredentialRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	req.Provider = services.NormalizeProvider(req.Provider)
+
+	host, err := services.HostFromURL(req.BaseURL)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// GitHub credentials are for Enterprise Server; github.com uses the profile token
+	if models.IsGitHubDotCom(req.Provider, host) {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Use the GitHub token in your profile for github.com")
+		return
+	}
+
+	// The token is sent to the base URL, which must not reach into the
+	// server's own network
+	if err := services.CheckCodeHostURL(req.BaseURL, h.allowedHosts); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	provider, err := services.NewProvider(req.Provider, req.BaseURL)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// The code host's answer is not passed on; it could be any server's
+	username, err := provider.ValidateToken(req.Token)
+	if err != nil {
+		log.Printf("Token validation against %s failed: %v", host, err)
+		if statusErr, ok := err.(*services.StatusError); ok && statusErr.StatusCode == http.StatusUnauthorized {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Token validation failed: the code host rejected the token")
+			return
+		}
+		utils.ErrorResponse(c, http.StatusBadRequest, "Token validation failed: the code host could not be reached or returned an error")
+		return
+	}
+
+	var credential models.Credential
+	err = database.DB.Where("user_id = ? AND provider = ? AND host = ?", userID, req.Provider, host).First(&credential).Error
+	if err != nil {
+		credential = models.Credential{
+			ID:       uuid.New(),
+			UserID:   userID,
+			Provider: req.Provider,
+			Host:     host,
+		}
+	}
+	credential.BaseURL = strings.TrimRight(req.BaseURL, "/")
+	credential.Token = strings.TrimSpace(req.Token)
+	credential.Username = username
+
+	if err := database.DB.Save(&credential).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to save credential")
+		return
+	}
+	services.ForgetRepositoryAccess(userID, credential.Provider, credential.Host)
+
+	utils.SuccessResponse(c, http.StatusCreated, credential)
+}
+
+func (h *UserHandler) DeleteCredential(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	credentialID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid credential ID")
+		return
+	}
+
+	var credential models.Credential
+	if err := database.DB.Where("id = ? AND user_id = ?", credentialID, userID).First(&credential).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Credential not found")
+		return
+	}
+
+	if err := database.DB.Delete(&credential).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete credential")
+		return
+	}
+	services.ForgetRepositoryAccess(userID, credential.Provider, credential.Host)
+
+	utils.SuccessResponse(c, http.StatusOK, gin.H{"message": "Credential deleted successfully"})
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strings"
+
+	"github-notes-backend/internal/config"
+	"github-notes-backend/internal/models"
+	"github-notes-backend/internal/services"
+	"github-notes-backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxWebhookPayload matches the largest payload GitHub sends (25 MB).
+const maxWebhookPayload = 25 << 20
+
+type WebhookHandler struct {
+	githubSecret string
+}
+
+func NewWebhookHandler(cfg *config.Config) *WebhookHandler {
+	return &WebhookHandler{
+		githubSecret: cfg.GithubWebhookSecret,
+	}
+}
+
+// GitHub receives GitHub webhook deliveries. The payload must be signed with
+// the configured secret; every delivery is logged and redeliveries of a
+// handled delivery are acknowledged without being applied again.
+func (h *WebhookHandler) GitHub(c *gin.Context) {
+	if h.githubSecret == "" {
+		utils.ErrorResponse(c, http.StatusServiceUnavailable, "GitHub webhook secret is not configured")
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxWebhookPayload))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to read payload")
+		return
+	}
+
+	if !validGitHubSignature(h.githubSecret, body, c.GetHeader("X-Hub-Signature-256")) {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid webhook signature")
+		return
+	}
+
+	deliveryID := c.GetHeader("X-GitHub-Delivery")
+	event := c.GetHeader("X-GitHub-Event")
+	if deliveryID == "" || event == "" {
+		utils.ErrorResponse(c, http.StatusBadRequest, "X-GitHub-Delivery and X-GitHub-Event headers are required")
+		return
+	}
+
+	delivery, duplicate, err := services.HandleGitHubWebhook(deliveryID, event, body)
+	if delivery == nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to record delivery")
+		return
+	}
+	if err != nil {
+		// Failing the delivery lets it be redelivered from GitHub
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to process delivery: "+err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, models.WebhookResponse{
+		DeliveryID: delivery.DeliveryID,
+		Status:     delivery.Status,
+		Summary:    delivery.Summary,
+		Duplicate:  duplicate,
+	})
+}
+
+// validGitHubSignature checks the "sha256=<hex hmac>" signature GitHub sends
+// in X-Hub-Signature-256.
+func validGitHubSignature(secret string, body []byte, signature string) bool {
+	expected, ok := strings.CutPrefix(signature, "sha256=")
+	if !ok {
+		return false
+	}
+	got, err := hex.DecodeString(expected)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github-notes-backend/internal/config"
+	"github-notes-backend/internal/database"
+	"github-notes-backend/internal/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const webhookTestSecret = "webhook-secret"
+
+// newWebhookTestDB points database.DB at a mock for the duration of the test.
+func newWebhookTestDB(t *testing.T) sqlmock.Sqlmock {
+	t.Helper()
+
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+		TranslateError: true,
+		Logger:         logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	previous := database.DB
+	database.DB = db
+	t.Cleanup(func() {
+		database.DB = previous
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+	return mock
+}
+
+// postWebhook sends a recorded payload to the GitHub webhook endpoint.
+// signature is computed with the configured secret when empty; pass "-" to
+// send no signature.
+func postWebhook(t *testing.T, event, deliveryID, fixture, signature string) (*httptest.ResponseRecorder, models.WebhookResponse) {
+	t.Helper()
+
+	body, err := os.ReadFile("testdata/" + fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signature == "" {
+		mac := hmac.New(sha256.New, []byte(webhookTestSecret))
+		mac.Write(body)
+		signature = "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/webhooks/github", NewWebhookHandler(&config.Config{GithubWebhookSecret: webhookTestSecret}).GitHub)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/webhooks/github", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-GitHub-Event", event)
+	req.Header.Set("X-GitHub-Delivery", deliveryID)
+	if signature != "-" {
+		req.Header.Set("X-Hub-Signature-256", signature)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var envelope struct {
+		Data models.WebhookResponse `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &envelope)
+	return w, envelope.Data
+}
+
+// expectDeliveryLogged expects a new delivery to be inserted.
+func expectDeliveryLogged(mock sqlmock.Sqlmock) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "webhook_deliveries" .* ON CONFLICT \("delivery_id"\) DO NOTHING`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mock.ExpectCommit()
+}
+
+// expectDeliverySaved expects the outcome to be stored on the delivery.
+func expectDeliverySaved(mock sqlmock.Sqlmock) {
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "webhook_deliveries"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+}
+
+// expectRegisteredRepository answers the registry lookup with acme/widgets,
+// stored lowercase while payloads spell it Acme/Widgets.
+func expectRegisteredRepository(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(`SELECT \* FROM "repositories" WHERE github_id = \$1`).
+		WithArgs(70312845, models.DefaultGitHubHost, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "provider", "host", "owner", "name", "github_id"}).
+			AddRow(uuid.New(), models.ProviderGitHub, models.DefaultGitHubHost, "acme", "widgets", 70312845))
+}
+
+func TestGitHubWebhookRejectsBadSignature(t *testing.T) {
+	// No query is expected: nothing may be logged or applied
+	newWebhookTestDB(t)
+
+	tests := []struct {
+		name      string
+		signature string
+	}{
+		{"missing", "-"},
+		{"wrong secret", "sha256=" + hex.EncodeToString(hmac.New(sha256.New, []byte("other")).Sum(nil))},
+		{"not hex", "sha256=zz"},
+		{"sha1", "sha1=6c8b2f4e0a9d7c5b3a1f8e6d4c2b0a9f8e7d6c5b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, _ := postWebhook(t, "pull_request", "5e9a0c1e-0871-11ef-8f6d-2f1e5c9a7b31", "pull_request_opened.json", tt.signature)
+			if w.Code != http.StatusUnauthorized {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusUnauthorized)
+			}
+		})
+	}
+}
+
+func TestGitHubWebhookPullRequest(t *testing.T) {
+	mock := newWebhookTestDB(t)
+
+	expectDeliveryLogged(mock)
+	expectRegisteredRepository(mock)
+	mock.ExpectQuery(`SELECT \* FROM "pull_requests" WHERE provider = \$1`).
+		WithArgs(models.ProviderGitHub, models.DefaultGitHubHost, "acme", "widgets", 214, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "pull_requests" .* ON CONFLICT`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectCommit()
+	mock.ExpectQuery(`SELECT \* FROM "pull_requests"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "repo_owner", "repo_name", "number", "title", "state"}).
+			AddRow(uuid.New(), "acme", "widgets", 214, "Cache widget thumbnails", "open"))
+	expectDeliverySaved(mock)
+
+	w, resp := postWebhook(t, "pull_request", "5e9a0c1e-0871-11ef-8f6d-2f1e5c9a7b31", "pull_request_opened.json", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body.String())
+	}
+	if resp.Status != models.WebhookProcessed || resp.Summary != "cached acme/widgets#214" || resp.Duplicate {
+		t.Errorf("response = %+v, want the PR cached", resp)
+	}
+}
+
+func TestGitHubWebhookIssueComment(t *testing.T) {
+	mock := newWebhookTestDB(t)
+
+	expectDeliveryLogged(mock)
+	expectRegisteredRepository(mock)
+	mock.ExpectQuery(`SELECT \* FROM "pull_requests" WHERE provider = \$1`).
+		WithArgs(models.ProviderGitHub, models.DefaultGitHubHost, "acme", "widgets", 214, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "repo_owner", "repo_name", "number"}).
+			AddRow(uuid.New(), "acme", "widgets", 214))
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "pr_comments" .* ON CONFLICT \("kind","github_id"\) DO UPDATE`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectCommit()
+	expectDeliverySaved(mock)
+
+	w, resp := postWebhook(t, "issue_comment", "7b21d4f0-0878-11ef-9b3c-8c4e2a1d6f55", "issue_comment_created.json", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body.String())
+	}
+	if resp.Status != models.WebhookProcessed || resp.Summary != "stored comment 2089912345 on Acme/Widgets#214" {
+		t.Errorf("response = %+v, want the comment stored", resp)
+	}
+}
+
+func TestGitHubWebhookReview(t *testing.T) {
+	mock := newWebhookTestDB(t)
+
+	expectDeliveryLogged(mock)
+	expectRegisteredRepository(mock)
+	mock.ExpectQuery(`SELECT \* FROM "pull_requests" WHERE provider = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "pull_requests" .* ON CONFLICT`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectCommit()
+	mock.ExpectQuery(`SELECT \* FROM "pull_requests"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "repo_owner", "repo_name", "number"}).
+			AddRow(uuid.New(), "acme", "widgets", 214))
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "pull_request_events"`).
+		WithArgs(sqlmock.AnyArg(), "review", "", "tmoreau: approved", models.EventSourceWebhook, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectCommit()
+	expectDeliverySaved(mock)
+
+	w, resp := postWebhook(t, "pull_request_review", "a03f6e52-0881-11ef-8d2a-61f0b7c3e9d4", "pull_request_review_submitted.json", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body.String())
+	}
+	if resp.Status != models.WebhookProcessed || resp.Summary != "cached acme/widgets#214, review 1998234571 by tmoreau approved" {
+		t.Errorf("response = %+v, want the review recorded", resp)
+	}
+}
+
+func TestGitHubWebhookDuplicateDelivery(t *testing.T) {
+	mock := newWebhookTestDB(t)
+
+	// The delivery is already logged, so the insert does nothing
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "webhook_deliveries" .* ON CONFLICT \("delivery_id"\) DO NOTHING`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectCommit()
+	mock.ExpectQuery(`SELECT \* FROM "webhook_deliveries" WHERE delivery_id = \$1`).
+		WithArgs("5e9a0c1e-0871-11ef-8f6d-2f1e5c9a7b31", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "delivery_id", "event", "status", "summary"}).
+			AddRow(uuid.New(), "5e9a0c1e-0871-11ef-8f6d-2f1e5c9a7b31", "pull_request", models.WebhookProcessed, "cached acme/widgets#214"))
+
+	w, resp := postWebhook(t, "pull_request", "5e9a0c1e-0871-11ef-8f6d-2f1e5c9a7b31", "pull_request_opened.json", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body.String())
+	}
+	if !resp.Duplicate || resp.Status != models.WebhookProcessed {
+		t.Errorf("response = %+v, want a processed duplicate", resp)
+	}
+}
@@ -0,0 +1,174 @@
+package jobs
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github-notes-backend/internal/config"
+	"github-notes-backend/internal/database"
+	"github-notes-backend/internal/models"
+	"github-notes-backend/internal/services"
+
+	"github.com/google/uuid"
+)
+
+// PRRefreshJob periodically refreshes stale PRs that notes link to, so PRs
+// nobody opens still pick up merges and closes.
+type PRRefreshJob struct {
+	refresher *services.PRRefresher
+	interval  time.Duration
+	batchSize int
+}
+
+func NewPRRefreshJob(cfg *config.Config, refresher *services.PRRefresher) *PRRefreshJob {
+	return &PRRefreshJob{
+		refresher: refresher,
+		interval:  cfg.PRRefreshInterval,
+		batchSize: cfg.PRRefreshBatchSize,
+	}
+}
+
+// Start runs the job every interval in the background. A zero interval
+// disables it.
+func (j *PRRefreshJob) Start() {
+	if j.interval <= 0 || j.batchSize <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(j.interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			j.RunOnce()
+		}
+	}()
+}
+
+// RunOnce refreshes up to batchSize of the longest-unchecked stale PRs. Each
+// PR is fetched with the credentials of one of the users whose notes link to
+// it; users close to their GitHub rate limit are left alone until it resets.
+func (j *PRRefreshJob) RunOnce() {
+	var prs []models.PullRequest
+	if err := database.DB.Scopes(j.refresher.StaleScope).
+		Where("EXISTS (SELECT 1 FROM note_pr_links JOIN notes ON notes.id = note_pr_links.note_id WHERE note_pr_links.pr_id = pull_requests.id AND notes.deleted_at IS NULL)").
+		Order("COALESCE(last_checked_at, updated_at) ASC").
+		Limit(j.batchSize).
+		Find(&prs).Error; err != nil {
+		log.Printf("PR refresh: failed to load stale pull requests: %v", err)
+		return
+	}
+	if len(prs) == 0 {
+		return
+	}
+
+	prIDs := make([]uuid.UUID, len(prs))
+	for i, pr := range prs {
+		prIDs[i] = pr.ID
+	}
+
+	var links []struct {
+		PRID   uuid.UUID
+		UserID uuid.UUID
+	}
+	if err := database.DB.Table("note_pr_links").
+		Distinct("note_pr_links.pr_id AS pr_id", "notes.user_id AS user_id").
+		Joins("JOIN notes ON notes.id = note_pr_links.note_id").
+		Where("note_pr_links.pr_id IN ? AND notes.deleted_at IS NULL", prIDs).
+		Scan(&links).Error; err != nil {
+		log.Printf("PR refresh: failed to load note owners: %v", err)
+		return
+	}
+
+	usersByPR := make(map[uuid.UUID][]uuid.UUID)
+	userIDs := make([]uuid.UUID, 0, len(links))
+	for _, link := range links {
+		usersByPR[link.PRID] = append(usersByPR[link.PRID], link.UserID)
+		userIDs = append(userIDs, link.UserID)
+	}
+
+	var users []models.User
+	if err := database.DB.Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+		log.Printf("PR refresh: failed to load users: %v", err)
+		return
+	}
+	usersByID := make(map[uuid.UUID]*models.User, len(users))
+	for i := range users {
+		usersByID[users[i].ID] = &users[i]
+	}
+
+	var credentials []models.Credential
+	if err := database.DB.Select("user_id", "provider", "host").
+		Where("user_id IN ?", userIDs).
+		Find(&credentials).Error; err != nil {
+		log.Printf("PR refresh: failed to load credentials: %v", err)
+		return
+	}
+	hasCredential := make(map[credentialKey]bool, len(credentials))
+	for _, credential := range credentials {
+		hasCredential[credentialKey{credential.UserID, credential.Provider, credential.Host}] = true
+	}
+
+	// Group the PRs by the user whose credentials will fetch them, so GitHub
+	// PRs of one user go out in as few GraphQL batches as possible
+	batches := make(map[uuid.UUID][]*models.PullRequest)
+	skipped := 0
+	for i := range prs {
+		pr := &prs[i]
+		user, waiting := pickUser(pr, usersByPR[pr.ID], usersByID, hasCredential)
+		if user == nil {
+			if !waiting {
+				// Nobody can fetch it; push it back a full TTL instead of
+				// retrying it at the head of every run
+				services.MarkFetchFailed(pr, fmt.Errorf("no linked user has credentials to refresh this pull request"))
+				services.SaveFetchStatus(pr)
+			}
+			skipped++
+			continue
+		}
+		batches[user.ID] = append(batches[user.ID], pr)
+	}
+
+	refreshed, failed := 0, 0
+	for userID, batch := range batches {
+		result := j.refresher.Refresh(batch, usersByID[userID], models.EventSourcePoll)
+		refreshed += result.Refreshed
+		failed += len(result.Failed)
+	}
+
+	log.Printf("PR refresh: %d refreshed, %d failed, %d skipped", refreshed, failed, skipped)
+}
+
+// credentialKey identifies a credential a user registered for a code host.
+type credentialKey struct {
+	UserID   uuid.UUID
+	Provider string
+	Host     string
+}
+
+// pickUser returns a user linking pr who can fetch it right now. Users without
+// a GitHub token cannot fetch github.com PRs, users without a credential for
+// the host cannot fetch PRs from other hosts, and users close to their rate
+// limit are skipped; waiting reports whether one of them will be usable once
+// the limit resets.
+func pickUser(pr *models.PullRequest, candidates []uuid.UUID, usersByID map[uuid.UUID]*models.User, hasCredential map[credentialKey]bool) (user *models.User, waiting bool) {
+	for _, id := range candidates {
+		candidate, ok := usersByID[id]
+		if !ok {
+			continue
+		}
+		if models.IsGitHubDotCom(pr.Provider, pr.Host) {
+			if candidate.GithubToken == "" {
+				continue
+			}
+			if services.NearRateLimit(candidate.GithubToken) {
+				waiting = true
+				continue
+			}
+		} else if !hasCredential[credentialKey{candidate.ID, pr.Provider, pr.Host}] {
+			continue
+		}
+		return candidate, false
+	}
+	return nil, waiting
+}
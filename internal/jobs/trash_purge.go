@@ -0,0 +1,64 @@
+package jobs
+
+import (
+	"log"
+	"time"
+
+	"github-notes-backend/internal/config"
+	"github-notes-backend/internal/services"
+)
+
+// trashPurgeBatchSize is how many notes are purged per transaction.
+const trashPurgeBatchSize = 100
+
+// TrashPurgeJob periodically deletes for good the notes that have been in the
+// trash longer than the retention period.
+type TrashPurgeJob struct {
+	interval  time.Duration
+	retention time.Duration
+}
+
+func NewTrashPurgeJob(cfg *config.Config) *TrashPurgeJob {
+	return &TrashPurgeJob{
+		interval:  cfg.TrashPurgeInterval,
+		retention: cfg.TrashRetention,
+	}
+}
+
+// Start runs the job every interval in the background. A zero interval or
+// retention disables it.
+func (j *TrashPurgeJob) Start() {
+	if j.interval <= 0 || j.retention <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(j.interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			j.RunOnce()
+		}
+	}()
+}
+
+// RunOnce purges every note trashed longer than the retention period ago.
+func (j *TrashPurgeJob) RunOnce() {
+	cutoff := time.Now().Add(-j.retention)
+
+	total := 0
+	for {
+		purged, err := services.PurgeTrashedBefore(cutoff, trashPurgeBatchSize)
+		if err != nil {
+			log.Printf("Trash purge: failed to purge notes: %v", err)
+			break
+		}
+		total += purged
+		if purged < trashPurgeBatchSize {
+			break
+		}
+	}
+
+	if total > 0 {
+		log.Printf("Trash purge: %d notes deleted", total)
+	}
+}
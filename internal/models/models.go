@@ -1,6 +1,7 @@
 package models
 
 import (
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -16,6 +17,10 @@ type User struct {
 	CreatedAt      time.Time `json:"created_at" gorm:"autoCreateTime"`
 	UpdatedAt      time.Time `json:"updated_at" gorm:"autoUpdateTime"`
 	Notes          []Note    `json:"notes,omitempty" gorm:"foreignKey:UserID"`
+
+	// RepositoriesSyncedAt is when the repositories the user can access were
+	// last pulled from GitHub; nil until the first sync
+	RepositoriesSyncedAt *time.Time `json:"-"`
 }
 
 // UserResponse represents user data for API responses
@@ -29,37 +34,508 @@ type UserResponse struct {
 }
 
 type Note struct {
-	ID             uuid.UUID     `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
-	UserID         uuid.UUID     `json:"user_id" gorm:"type:uuid;not null"`
-	Title          string        `json:"title" gorm:"type:varchar(255);not null"`
-	Content        string        `json:"content" gorm:"type:text"`
-	GithubPRNumber *int          `json:"github_pr_number,omitempty" gorm:""`
-	RepoOwner      string        `json:"repo_owner,omitempty" gorm:""`
-	RepoName       string        `json:"repo_name,omitempty" gorm:""`
-	CreatedAt      time.Time     `json:"created_at" gorm:"autoCreateTime"`
-	UpdatedAt      time.Time     `json:"updated_at" gorm:"autoUpdateTime"`
-	User           User          `json:"user,omitempty" gorm:"foreignKey:UserID"`
-	PullRequests   []PullRequest `json:"pull_requests,omitempty" gorm:"many2many:note_pr_links;"`
+	ID           uuid.UUID     `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	UserID       uuid.UUID     `json:"user_id" gorm:"type:uuid;not null"`
+	Title        string        `json:"title" gorm:"type:varchar(255);not null"`
+	Content      string        `json:"content" gorm:"type:text"`
+	RepositoryID *uuid.UUID    `json:"repository_id,omitempty" gorm:"type:uuid;index"`
+	NotebookID   *uuid.UUID    `json:"notebook_id,omitempty" gorm:"type:uuid;index"`
+	CreatedAt    time.Time     `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt    time.Time     `json:"updated_at" gorm:"autoUpdateTime"`
+	User         User          `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	PullRequests []PullRequest `json:"pull_requests,omitempty" gorm:"many2many:note_pr_links;joinForeignKey:NoteID;joinReferences:PRID"`
+	Issues       []Issue       `json:"issues,omitempty" gorm:"many2many:note_issue_links;"`
+	Commits      []Commit      `json:"commits,omitempty" gorm:"many2many:note_commit_links;"`
+	Tags         []Tag         `json:"tags,omitempty" gorm:"many2many:note_tags;"`
+	// PRSnapshots freeze linked PRs as they were when the note was written
+	PRSnapshots []NotePRSnapshot `json:"pr_snapshots,omitempty" gorm:"foreignKey:NoteID"`
+	// SourceUnavailable is set when any linked PR can no longer be fetched
+	SourceUnavailable bool `json:"source_unavailable" gorm:"-"`
+
+	// DeletedAt is set while the note is in the trash
+	DeletedAt gorm.DeletedAt `json:"deleted_at,omitempty" gorm:"index"`
+
+	// Where the note was last published on GitHub, so republishing updates
+	// the same comment or review instead of creating a new one
+	PublishedPRID      *uuid.UUID `json:"published_pr_id,omitempty" gorm:"type:uuid"`
+	PublishedKind      string     `json:"published_kind,omitempty" gorm:""`
+	PublishedCommentID *int64     `json:"published_comment_id,omitempty" gorm:""`
+	PublishedURL       string     `json:"published_url,omitempty" gorm:""`
+	PublishedAt        *time.Time `json:"published_at,omitempty" gorm:""`
 }
 
+// PullRequest caches a change request from any code host: a GitHub or Gitea
+// pull request or a GitLab merge request (Number holds the MR iid). State is
+// normalized to open, closed or merged across providers.
 type PullRequest struct {
+	ID           uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	Provider     string     `json:"provider" gorm:"not null;default:github"`
+	Host         string     `json:"host" gorm:"not null;default:github.com"`
+	Number       int        `json:"number" gorm:"not null"`
+	RepoOwner    string     `json:"repo_owner" gorm:"not null"`
+	RepoName     string     `json:"repo_name" gorm:"not null"`
+	RepositoryID *uuid.UUID `json:"repository_id,omitempty" gorm:"type:uuid;index"`
+	Title        string     `json:"title" gorm:"not null"`
+	Body         string     `json:"body" gorm:"type:text"`
+	Author       string     `json:"author" gorm:"not null"`
+	State        string     `json:"state" gorm:"not null"`
+	Draft        bool       `json:"draft" gorm:"not null;default:false"`
+	HeadSHA      string     `json:"head_sha" gorm:""`
+	MergedAt     *time.Time `json:"merged_at,omitempty" gorm:""`
+	URL          string     `json:"url" gorm:"not null"`
+	// Private PRs are only shown to users whose credentials can read them
+	Private bool `json:"private" gorm:"not null;default:false"`
+	// Review decision and combined CI result of the head commit, lower case
+	// as reported by GitHub (e.g. "approved", "failure"); empty when unknown
+	ReviewDecision string `json:"review_decision,omitempty" gorm:""`
+	CIStatus       string `json:"ci_status,omitempty" gorm:""`
+	// HasChecks is set on freshly fetched data that carries ReviewDecision and
+	// CIStatus; only GitHub GraphQL reports them
+	HasChecks bool `json:"-" gorm:"-"`
+	// CommentsSyncedAt is the "since" cursor for incremental comment syncing
+	CommentsSyncedAt *time.Time `json:"comments_synced_at,omitempty" gorm:""`
+	// Outcome of the last fetch from the code host
+	FetchStatus   string     `json:"fetch_status" gorm:"not null;default:ok;index"`
+	LastError     string     `json:"last_error,omitempty" gorm:"type:text"`
+	LastCheckedAt *time.Time `json:"last_checked_at,omitempty" gorm:""`
+	// SourceUnavailable tells clients the cached copy can no longer be
+	// refreshed because the PR was deleted or access to it was lost
+	SourceUnavailable bool `json:"source_unavailable" gorm:"-"`
+	// Restricted is set when the caller cannot read the PR; its cached
+	// details are blanked out
+	Restricted bool      `json:"restricted,omitempty" gorm:"-"`
+	CreatedAt  time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt  time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+	Notes      []Note    `json:"notes,omitempty" gorm:"many2many:note_pr_links;joinForeignKey:PRID;joinReferences:NoteID"`
+}
+
+// Pull request fetch statuses
+const (
+	FetchStatusOK        = "ok"
+	FetchStatusNotFound  = "not_found"
+	FetchStatusForbidden = "forbidden"
+)
+
+// UnavailableFetchStatuses are the fetch statuses of PRs whose source can no
+// longer be reached.
+var UnavailableFetchStatuses = []string{FetchStatusNotFound, FetchStatusForbidden}
+
+// Unavailable reports whether the PR's source can no longer be reached.
+func (pr *PullRequest) Unavailable() bool {
+	return pr.FetchStatus == FetchStatusNotFound || pr.FetchStatus == FetchStatusForbidden
+}
+
+// Restrict blanks out everything about the PR except where it lives, for
+// callers who cannot read it.
+func (pr *PullRequest) Restrict() {
+	*pr = PullRequest{
+		ID:           pr.ID,
+		Provider:     pr.Provider,
+		Host:         pr.Host,
+		Number:       pr.Number,
+		RepoOwner:    pr.RepoOwner,
+		RepoName:     pr.RepoName,
+		RepositoryID: pr.RepositoryID,
+		Private:      pr.Private,
+		Restricted:   true,
+	}
+}
+
+// NotePRSnapshot is an immutable copy of a linked PR taken when a note was
+// created or updated with snapshot_pr set. Divergence lists the fields whose
+// current value no longer matches the snapshot.
+type NotePRSnapshot struct {
+	ID             uuid.UUID     `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	NoteID         uuid.UUID     `json:"note_id" gorm:"type:uuid;not null;uniqueIndex:idx_note_pr_snapshots_note_pr"`
+	PullRequestID  uuid.UUID     `json:"pull_request_id" gorm:"type:uuid;not null;uniqueIndex:idx_note_pr_snapshots_note_pr"`
+	HeadSHA        string        `json:"head_sha" gorm:""`
+	Title          string        `json:"title" gorm:""`
+	Body           string        `json:"body" gorm:"type:text"`
+	State          string        `json:"state" gorm:""`
+	ReviewDecision string        `json:"review_decision,omitempty" gorm:""`
+	CapturedAt     time.Time     `json:"captured_at" gorm:"not null"`
+	Divergence     []FieldChange `json:"divergence" gorm:"-"`
+	// Restricted is set when the caller can no longer read the PR
+	Restricted bool `json:"restricted,omitempty" gorm:"-"`
+}
+
+// SnapshotOf captures the snapshotted fields of pr.
+func SnapshotOf(noteID uuid.UUID, pr *PullRequest) NotePRSnapshot {
+	return NotePRSnapshot{
+		NoteID:         noteID,
+		PullRequestID:  pr.ID,
+		HeadSHA:        pr.HeadSHA,
+		Title:          pr.Title,
+		Body:           pr.Body,
+		State:          pr.State,
+		ReviewDecision: pr.ReviewDecision,
+		CapturedAt:     time.Now(),
+	}
+}
+
+// Compare fills in Divergence against the PR's current values, or blanks
+// the snapshot out when the PR is restricted for the caller.
+func (s *NotePRSnapshot) Compare(current *PullRequest) {
+	if current.Restricted {
+		*s = NotePRSnapshot{
+			ID:            s.ID,
+			NoteID:        s.NoteID,
+			PullRequestID: s.PullRequestID,
+			CapturedAt:    s.CapturedAt,
+			Divergence:    []FieldChange{},
+			Restricted:    true,
+		}
+		return
+	}
+
+	s.Divergence = []FieldChange{}
+	add := func(field, snapshot, now string) {
+		if snapshot != now {
+			s.Divergence = append(s.Divergence, FieldChange{Field: field, Before: snapshot, After: now})
+		}
+	}
+	add("head_sha", s.HeadSHA, current.HeadSHA)
+	add("title", s.Title, current.Title)
+	add("body", s.Body, current.Body)
+	add("state", s.State, current.State)
+	add("review_decision", s.ReviewDecision, current.ReviewDecision)
+}
+
+// Sources of observed PR changes
+const (
+	EventSourceWebhook = "webhook"
+	EventSourcePoll    = "poll"
+	EventSourceManual  = "manual"
+)
+
+// PullRequestEvent records one observed change to a field of a cached PR,
+// so the previous value survives the row being overwritten.
+type PullRequestEvent struct {
+	ID            uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	PullRequestID uuid.UUID `json:"pull_request_id" gorm:"type:uuid;not null;index:idx_pr_events_pr_observed"`
+	Field         string    `json:"field" gorm:"not null"`
+	OldValue      string    `json:"old_value" gorm:"type:text"`
+	NewValue      string    `json:"new_value" gorm:"type:text"`
+	Source        string    `json:"source" gorm:"not null"`
+	ObservedAt    time.Time `json:"observed_at" gorm:"not null;index:idx_pr_events_pr_observed"`
+	CreatedAt     time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// Pull request comment kinds
+const (
+	PRCommentIssue  = "issue_comment"
+	PRCommentReview = "review_comment"
+)
+
+// PRComment is a read-only copy of a comment from a pull request conversation,
+// keyed by its GitHub comment id.
+type PRComment struct {
+	ID              uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	PullRequestID   uuid.UUID `json:"pull_request_id" gorm:"type:uuid;not null;index"`
+	GithubID        int64     `json:"github_id" gorm:"not null;uniqueIndex:idx_pr_comments_kind_github_id"`
+	Kind            string    `json:"kind" gorm:"not null;uniqueIndex:idx_pr_comments_kind_github_id"`
+	Author          string    `json:"author" gorm:""`
+	Body            string    `json:"body" gorm:"type:text"`
+	Path            string    `json:"path,omitempty" gorm:""`
+	Line            *int      `json:"line,omitempty" gorm:""`
+	InReplyToID     *int64    `json:"in_reply_to_id,omitempty" gorm:""`
+	URL             string    `json:"url" gorm:""`
+	GithubCreatedAt time.Time `json:"github_created_at" gorm:""`
+	GithubUpdatedAt time.Time `json:"github_updated_at" gorm:""`
+	CreatedAt       time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt       time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+type PRCommentsResponse struct {
+	Comments  []PRComment `json:"comments"`
+	SyncedAt  *time.Time  `json:"synced_at"`
+	SyncError string      `json:"sync_error,omitempty"`
+}
+
+// Repository is the registry entry for a repository on a code host. Notes and
+// cached PRs reference it so that one repository maps to one row no matter
+// how its owner and name were typed.
+type Repository struct {
+	ID            uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	Provider      string    `json:"provider" gorm:"not null;default:github;uniqueIndex:idx_repositories_provider_host_owner_name"`
+	Host          string    `json:"host" gorm:"not null;default:github.com;uniqueIndex:idx_repositories_provider_host_owner_name"`
+	Owner         string    `json:"owner" gorm:"not null;uniqueIndex:idx_repositories_provider_host_owner_name"`
+	Name          string    `json:"name" gorm:"not null;uniqueIndex:idx_repositories_provider_host_owner_name"`
+	GithubID      *int64    `json:"github_id,omitempty" gorm:"index"`
+	DefaultBranch string    `json:"default_branch" gorm:""`
+	Private       bool      `json:"private" gorm:"not null;default:false"`
+	Description   string    `json:"description" gorm:"type:text"`
+	URL           string    `json:"url" gorm:""`
+	CreatedAt     time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt     time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// FullName returns the repository as "owner/name".
+func (r *Repository) FullName() string {
+	return r.Owner + "/" + r.Name
+}
+
+// Webhook delivery statuses
+const (
+	WebhookProcessed = "processed"
+	WebhookIgnored   = "ignored"
+	WebhookFailed    = "failed"
+)
+
+// WebhookDelivery logs a webhook received from a code host so that it can be
+// inspected and replayed. DeliveryID is the host's delivery id, used to drop
+// redeliveries that were already handled.
+type WebhookDelivery struct {
+	ID          uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	Provider    string     `json:"provider" gorm:"not null;default:github"`
+	DeliveryID  string     `json:"delivery_id" gorm:"not null;uniqueIndex"`
+	Event       string     `json:"event" gorm:"not null;index"`
+	Action      string     `json:"action" gorm:""`
+	Repository  string     `json:"repository" gorm:""`
+	Status      string     `json:"status" gorm:"not null;index"`
+	Summary     string     `json:"summary" gorm:""`
+	Error       string     `json:"error,omitempty" gorm:"type:text"`
+	Attempts    int        `json:"attempts" gorm:"not null;default:0"`
+	Payload     string     `json:"-" gorm:"type:jsonb;not null"`
+	ProcessedAt *time.Time `json:"processed_at,omitempty" gorm:""`
+	CreatedAt   time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// SchemaMigration records a data migration that has been applied.
+type SchemaMigration struct {
+	ID        string    `gorm:"primaryKey"`
+	AppliedAt time.Time `gorm:"autoCreateTime"`
+}
+
+type NotePRLink struct {
+	NoteID uuid.UUID `json:"note_id" gorm:"type:uuid;primaryKey"`
+	PRID   uuid.UUID `json:"pr_id" gorm:"type:uuid;primaryKey"`
+	// Detected links come from references in the note content and go away
+	// when the content stops referencing them
+	Detected bool `json:"detected" gorm:"not null;default:false"`
+}
+
+// Issue caches GitHub issue metadata the same way PullRequest caches PRs.
+type Issue struct {
+	ID uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	// Host is github.com or a GitHub Enterprise Server instance
+	Host        string    `json:"host" gorm:"not null;default:github.com"`
+	Number      int       `json:"number" gorm:"not null"`
+	RepoOwner   string    `json:"repo_owner" gorm:"not null"`
+	RepoName    string    `json:"repo_name" gorm:"not null"`
+	Title       string    `json:"title" gorm:"not null"`
+	Body        string    `json:"body" gorm:"type:text"`
+	Author      string    `json:"author" gorm:"not null"`
+	State       string    `json:"state" gorm:"not null"`
+	StateReason string    `json:"state_reason,omitempty" gorm:""`
+	Labels      []string  `json:"labels" gorm:"type:jsonb;serializer:json"`
+	Assignees   []string  `json:"assignees" gorm:"type:jsonb;serializer:json"`
+	URL         string    `json:"url" gorm:"not null"`
+	CreatedAt   time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+	Notes       []Note    `json:"notes,omitempty" gorm:"many2many:note_issue_links;"`
+	// Private issues are only shown to users whose credentials can read them
+	Private bool `json:"private" gorm:"not null;default:false"`
+	// Restricted is set when the caller cannot read the issue
+	Restricted bool `json:"restricted,omitempty" gorm:"-"`
+}
+
+// Restrict blanks out everything about the issue except where it lives, for
+// callers who cannot read it.
+func (issue *Issue) Restrict() {
+	*issue = Issue{
+		ID:         issue.ID,
+		Host:       issue.Host,
+		Number:     issue.Number,
+		RepoOwner:  issue.RepoOwner,
+		RepoName:   issue.RepoName,
+		Private:    issue.Private,
+		Restricted: true,
+	}
+}
+
+type NoteIssueLink struct {
+	NoteID   uuid.UUID `json:"note_id" gorm:"type:uuid;primaryKey"`
+	IssueID  uuid.UUID `json:"issue_id" gorm:"type:uuid;primaryKey"`
+	Detected bool      `json:"detected" gorm:"not null;default:false"`
+}
+
+// Commit caches GitHub commit metadata. SHA is always the full 40 character
+// hash, even when the note referenced the commit by a short SHA.
+type Commit struct {
+	ID            uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	SHA           string    `json:"sha" gorm:"type:varchar(40);not null;index"`
+	RepoOwner     string    `json:"repo_owner" gorm:"not null"`
+	RepoName      string    `json:"repo_name" gorm:"not null"`
+	Message       string    `json:"message" gorm:"type:text"`
+	Author        string    `json:"author" gorm:""`
+	AuthorEmail   string    `json:"author_email" gorm:""`
+	AuthoredAt    time.Time `json:"authored_at" gorm:""`
+	Parents       []string  `json:"parents" gorm:"type:jsonb;serializer:json"`
+	Additions     int       `json:"additions" gorm:"not null;default:0"`
+	Deletions     int       `json:"deletions" gorm:"not null;default:0"`
+	ChangedFiles  int       `json:"changed_files" gorm:"not null;default:0"`
+	AssociatedPRs []int     `json:"associated_prs" gorm:"type:jsonb;serializer:json"`
+	URL           string    `json:"url" gorm:"not null"`
+	CreatedAt     time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt     time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+	Notes         []Note    `json:"notes,omitempty" gorm:"many2many:note_commit_links;"`
+	// Private commits are only shown to users whose credentials can read them
+	Private bool `json:"private" gorm:"not null;default:false"`
+	// Restricted is set when the caller cannot read the commit
+	Restricted bool `json:"restricted,omitempty" gorm:"-"`
+}
+
+// Restrict blanks out everything about the commit except where it lives, for
+// callers who cannot read it.
+func (commit *Commit) Restrict() {
+	*commit = Commit{
+		ID:         commit.ID,
+		SHA:        commit.SHA,
+		RepoOwner:  commit.RepoOwner,
+		RepoName:   commit.RepoName,
+		Private:    commit.Private,
+		Restricted: true,
+	}
+}
+
+type NoteCommitLink struct {
+	NoteID   uuid.UUID `json:"note_id" gorm:"type:uuid;primaryKey"`
+	CommitID uuid.UUID `json:"commit_id" gorm:"type:uuid;primaryKey"`
+}
+
+// NoteRevision is a saved state of a note: its title, content and links
+// after an edit. Revisions are numbered from 1 per note and the newest one
+// matches the note as it is now.
+type NoteRevision struct {
+	ID        uuid.UUID      `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	NoteID    uuid.UUID      `json:"note_id" gorm:"type:uuid;not null;uniqueIndex:idx_note_revisions_note_number"`
+	Number    int            `json:"number" gorm:"not null;uniqueIndex:idx_note_revisions_note_number"`
+	Title     string         `json:"title" gorm:"type:varchar(255);not null"`
+	Content   string         `json:"content" gorm:"type:text"`
+	Links     []RevisionLink `json:"links" gorm:"type:jsonb;serializer:json"`
+	EditorID  uuid.UUID      `json:"editor_id" gorm:"type:uuid;not null"`
+	CreatedAt time.Time      `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// RevisionLink is a PR, issue or commit a note was linked to in a revision.
+// Ref is a readable reference such as owner/repo#12.
+type RevisionLink struct {
+	Kind     string    `json:"kind"`
+	ID       uuid.UUID `json:"id"`
+	Ref      string    `json:"ref"`
+	URL      string    `json:"url"`
+	Detected bool      `json:"detected,omitempty"`
+}
+
+// Notebook is a folder of notes. Notebooks nest through ParentID; top level
+// notebooks have none. Position orders a notebook among its siblings.
+type Notebook struct {
+	ID        uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	UserID    uuid.UUID  `json:"user_id" gorm:"type:uuid;not null;index"`
+	ParentID  *uuid.UUID `json:"parent_id" gorm:"type:uuid;index"`
+	Name      string     `json:"name" gorm:"type:varchar(100);not null"`
+	Position  int        `json:"position" gorm:"not null;default:0"`
+	CreatedAt time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// DefaultTagColor is used for tags created without a color.
+const DefaultTagColor = "#6c757d"
+
+// Tag groups a user's notes. Names are unique per user, ignoring case.
+type Tag struct {
 	ID        uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
-	Number    int       `json:"number" gorm:"not null"`
-	RepoOwner string    `json:"repo_owner" gorm:"not null"`
-	RepoName  string    `json:"repo_name" gorm:"not null"`
-	Title     string    `json:"title" gorm:"not null"`
-	Body      string    `json:"body" gorm:"type:text"`
-	Author    string    `json:"author" gorm:"not null"`
-	State     string    `json:"state" gorm:"not null"`
-	URL       string    `json:"url" gorm:"not null"`
+	UserID    uuid.UUID `json:"user_id" gorm:"type:uuid;not null;index"`
+	Name      string    `json:"name" gorm:"type:varchar(50);not null"`
+	Color     string    `json:"color" gorm:"type:varchar(7);not null;default:'#6c757d'"`
 	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
 	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
-	Notes     []Note    `json:"notes,omitempty" gorm:"many2many:note_pr_links;"`
+	Notes     []Note    `json:"notes,omitempty" gorm:"many2many:note_tags;"`
 }
 
-type NotePRLink struct {
+type NoteTag struct {
 	NoteID uuid.UUID `json:"note_id" gorm:"type:uuid;primaryKey"`
-	PRID   uuid.UUID `json:"pr_id" gorm:"type:uuid;primaryKey"`
+	TagID  uuid.UUID `json:"tag_id" gorm:"type:uuid;primaryKey;index"`
+}
+
+// Code host providers
+const (
+	ProviderGitHub = "github"
+	ProviderGitLab = "gitlab"
+	// ProviderGitea covers Forgejo as well, which shares Gitea's API;
+	// "forgejo" is accepted as an alias in requests
+	ProviderGitea   = "gitea"
+	ProviderForgejo = "forgejo"
+
+	DefaultGitHubHost = "github.com"
+)
+
+// IsGitHubDotCom reports whether provider and host name github.com rather
+// than a GitHub Enterprise Server instance or another code host. An empty
+// host means github.com.
+func IsGitHubDotCom(provider, host string) bool {
+	return provider == ProviderGitHub && (host == "" || host == DefaultGitHubHost)
+}
+
+// Reference kinds
+const (
+	RefPullRequest = "pull_request"
+	RefIssue       = "issue"
+	RefCommit      = "commit"
+)
+
+// Reference points a note at a pull request (a merge request on GitLab), an
+// issue or a commit. Pull requests and issues are identified by Number, commits
+// by SHA. Provider defaults to GitHub; Host selects a self-hosted instance.
+// A URL can be given instead of the repository and number fields.
+type Reference struct {
+	Kind      string `json:"kind" binding:"omitempty,oneof=pull_request issue commit"`
+	Provider  string `json:"provider,omitempty" binding:"omitempty,oneof=github gitlab gitea forgejo"`
+	Host      string `json:"host,omitempty"`
+	RepoOwner string `json:"repo_owner" binding:"required_without=URL"`
+	RepoName  string `json:"repo_name" binding:"required_without=URL"`
+	Number    int    `json:"number,omitempty" binding:"omitempty,gt=0"`
+	SHA       string `json:"sha,omitempty" binding:"omitempty,max=40"`
+	URL       string `json:"url,omitempty" binding:"omitempty,url"`
+}
+
+// Credential holds a user's token for a code host other than github.com,
+// such as a self-hosted GitLab or Forgejo instance.
+type Credential struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	UserID    uuid.UUID `json:"user_id" gorm:"type:uuid;not null;uniqueIndex:idx_credentials_user_provider_host"`
+	Provider  string    `json:"provider" gorm:"not null;uniqueIndex:idx_credentials_user_provider_host"`
+	Host      string    `json:"host" gorm:"not null;uniqueIndex:idx_credentials_user_provider_host"`
+	BaseURL   string    `json:"base_url" gorm:"not null"`
+	Token     string    `json:"-" gorm:"not null"`
+	Username  string    `json:"username" gorm:""`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// RepositoryAccess caches whether a user's credentials can read a private
+// repository. Both grants and denials expire so that access changes on the
+// code host are picked up.
+type RepositoryAccess struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	UserID    uuid.UUID `json:"user_id" gorm:"type:uuid;not null;uniqueIndex:idx_repository_access_key"`
+	Provider  string    `json:"provider" gorm:"not null;uniqueIndex:idx_repository_access_key"`
+	Host      string    `json:"host" gorm:"not null;uniqueIndex:idx_repository_access_key"`
+	RepoOwner string    `json:"repo_owner" gorm:"not null;uniqueIndex:idx_repository_access_key"`
+	RepoName  string    `json:"repo_name" gorm:"not null;uniqueIndex:idx_repository_access_key"`
+	Granted   bool      `json:"granted" gorm:"not null"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"not null;index"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// UserRepository records a repository the user could access on GitHub at the
+// last repository sync.
+type UserRepository struct {
+	UserID       uuid.UUID `json:"user_id" gorm:"type:uuid;primaryKey"`
+	RepositoryID uuid.UUID `json:"repository_id" gorm:"type:uuid;primaryKey;index"`
 }
 
 // Request/Response DTOs
@@ -83,20 +559,275 @@ type UpdateProfileRequest struct {
 	GithubToken    string `json:"github_token"`
 }
 
+type CreateCredentialRequest struct {
+	Provider string `json:"provider" binding:"required,oneof=github gitlab gitea forgejo"`
+	BaseURL  string `json:"base_url" binding:"required,url"`
+	Token    string `json:"token" binding:"required"`
+}
+
 type CreateNoteRequest struct {
-	Title          string `json:"title" binding:"required,max=255"`
-	Content        string `json:"content"`
-	GithubPRNumber *int   `json:"github_pr_number,omitempty"`
-	RepoOwner      string `json:"repo_owner,omitempty"`
-	RepoName       string `json:"repo_name,omitempty"`
+	Title   string `json:"title" binding:"required,max=255"`
+	Content string `json:"content"`
+	// Tags are tag names; missing tags are created
+	Tags       []string   `json:"tags,omitempty" binding:"omitempty,dive,required,max=50"`
+	NotebookID *uuid.UUID `json:"notebook_id,omitempty"`
+	NoteLinks
 }
 
+// UpdateNoteRequest replaces the note's links when it references anything;
+// an empty github_refs list removes them all. Without references the links
+// are left alone. Tags work the same way: a tags list, even an empty one,
+// replaces the note's tags.
 type UpdateNoteRequest struct {
-	Title          string `json:"title" binding:"required,max=255"`
-	Content        string `json:"content"`
+	Title   string   `json:"title" binding:"required,max=255"`
+	Content string   `json:"content"`
+	Tags    []string `json:"tags" binding:"omitempty,dive,required,max=50"`
+	NoteLinks
+}
+
+// NoteLinks are the GitHub items a create or update request links the note to.
+type NoteLinks struct {
+	// GithubRefs lists every item to link. github_ref, pr_url and the
+	// github_pr_number/repo_owner/repo_name shorthand each add one more.
+	// References in the content are linked on top of these.
+	GithubRefs []Reference `json:"github_refs,omitempty" binding:"omitempty,dive"`
+	GithubRef  *Reference  `json:"github_ref,omitempty"`
+	// PRURL is a pull request URL, an alternative to the shorthand fields
+	PRURL          string `json:"pr_url,omitempty" binding:"omitempty,url"`
 	GithubPRNumber *int   `json:"github_pr_number,omitempty"`
 	RepoOwner      string `json:"repo_owner,omitempty"`
 	RepoName       string `json:"repo_name,omitempty"`
+	// CommitRef links a commit written as "owner/repo@sha"
+	CommitRef string `json:"commit_ref,omitempty"`
+	// SnapshotPR freezes the linked PRs as they are now
+	SnapshotPR bool `json:"snapshot_pr,omitempty"`
+}
+
+// AddNoteLinkRequest links one more GitHub item to a note.
+type AddNoteLinkRequest struct {
+	Reference
+	// SnapshotPR freezes the linked PRs as they are now
+	SnapshotPR bool `json:"snapshot_pr,omitempty"`
+}
+
+// CreateTagRequest creates a tag. Colors are #RGB or #RRGGBB; hexcolor alone
+// also accepts an alpha channel, which does not fit the column.
+type CreateTagRequest struct {
+	Name  string `json:"name" binding:"required,max=50"`
+	Color string `json:"color" binding:"omitempty,hexcolor,len=4|len=7"`
+}
+
+// UpdateTagRequest renames or recolors a tag; empty fields are left alone.
+type UpdateTagRequest struct {
+	Name  string `json:"name" binding:"omitempty,max=50"`
+	Color string `json:"color" binding:"omitempty,hexcolor,len=4|len=7"`
+}
+
+type MergeTagRequest struct {
+	IntoID uuid.UUID `json:"into_id" binding:"required"`
+}
+
+type TagListItem struct {
+	Tag
+	NoteCount int64 `json:"note_count"`
+}
+
+// CreateNotebookRequest adds a notebook under parent_id, or at the top level
+// without one. Without a position it goes after its siblings.
+type CreateNotebookRequest struct {
+	Name     string     `json:"name" binding:"required,max=100"`
+	ParentID *uuid.UUID `json:"parent_id,omitempty"`
+	Position *int       `json:"position,omitempty" binding:"omitempty,min=0"`
+}
+
+type UpdateNotebookRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+// MoveNotebookRequest places a notebook under parent_id, or at the top level
+// when it is null, at position among its new siblings. Passing the current
+// parent reorders the notebook in place.
+type MoveNotebookRequest struct {
+	ParentID *uuid.UUID `json:"parent_id"`
+	Position *int       `json:"position,omitempty" binding:"omitempty,min=0"`
+}
+
+// MoveNoteRequest files a note in a notebook, or takes it out of its notebook
+// when notebook_id is null.
+type MoveNoteRequest struct {
+	NotebookID *uuid.UUID `json:"notebook_id"`
+}
+
+// Ways of deleting a notebook that still has contents
+const (
+	NotebookDeleteCascade = "cascade"
+	NotebookDeleteMoveUp  = "move_up"
+)
+
+// NotebookTreeItem is a notebook with its sub-notebooks. NoteCount counts the
+// notes filed directly in it, TotalNoteCount those in its whole subtree.
+type NotebookTreeItem struct {
+	Notebook
+	NoteCount      int64              `json:"note_count"`
+	TotalNoteCount int64              `json:"total_note_count"`
+	Children       []NotebookTreeItem `json:"children"`
+}
+
+type NoteRevisionsResponse struct {
+	Revisions []NoteRevision `json:"revisions"`
+	Total     int64          `json:"total"`
+	Page      int            `json:"page"`
+	Limit     int            `json:"limit"`
+}
+
+// TrashedNote is a note in the trash. PurgeAt is when it will be deleted for
+// good, unset when the trash is never emptied automatically.
+type TrashedNote struct {
+	Note
+	PurgeAt *time.Time `json:"purge_at,omitempty"`
+}
+
+type TrashResponse struct {
+	Notes []TrashedNote `json:"notes"`
+	Total int64         `json:"total"`
+	Page  int           `json:"page"`
+	Limit int           `json:"limit"`
+}
+
+// Revision diff modes
+const (
+	DiffModeUnified = "unified"
+	DiffModeWord    = "word"
+)
+
+// Diff chunk operations
+const (
+	DiffEqual  = "equal"
+	DiffInsert = "insert"
+	DiffDelete = "delete"
+)
+
+// DiffChunk is a run of text that is unchanged, inserted or deleted.
+type DiffChunk struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// NoteRevisionDiff compares two revisions of a note. The title is always
+// diffed word by word; the content is either a unified diff or word chunks,
+// depending on the mode.
+type NoteRevisionDiff struct {
+	From         int            `json:"from"`
+	To           int            `json:"to"`
+	Mode         string         `json:"mode"`
+	Title        []DiffChunk    `json:"title"`
+	Unified      string         `json:"unified,omitempty"`
+	Content      []DiffChunk    `json:"content,omitempty"`
+	LinksAdded   []RevisionLink `json:"links_added"`
+	LinksRemoved []RevisionLink `json:"links_removed"`
+}
+
+// Publish modes
+const (
+	PublishModeComment = "comment"
+	PublishModeReview  = "review"
+)
+
+type PublishNoteRequest struct {
+	Mode          string     `json:"mode" binding:"omitempty,oneof=comment review"`
+	PullRequestID *uuid.UUID `json:"pull_request_id,omitempty"`
+}
+
+// Inbox relations between the caller and a pull request
+const (
+	InboxAuthored        = "authored"
+	InboxReviewRequested = "review_requested"
+	InboxAssigned        = "assigned"
+)
+
+// InboxPullRequest is a GitHub PR the caller is involved in, annotated with
+// whether notes already exist for it.
+type InboxPullRequest struct {
+	RepoOwner     string     `json:"repo_owner"`
+	RepoName      string     `json:"repo_name"`
+	Number        int        `json:"number"`
+	Title         string     `json:"title"`
+	State         string     `json:"state"`
+	Draft         bool       `json:"draft"`
+	Author        string     `json:"author"`
+	URL           string     `json:"url"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	Relations     []string   `json:"relations"`
+	PullRequestID *uuid.UUID `json:"pull_request_id,omitempty"`
+	NoteCount     int64      `json:"note_count"`
+	HasNotes      bool       `json:"has_notes"`
+}
+
+type InboxResponse struct {
+	PullRequests []InboxPullRequest `json:"pull_requests"`
+	Total        int                `json:"total"`
+	Page         int                `json:"page"`
+	Limit        int                `json:"limit"`
+	// TotalCounts is how many PRs GitHub matched per relation; Incomplete is
+	// set when the list holds fewer than that
+	TotalCounts map[string]int `json:"total_counts"`
+	Incomplete  bool           `json:"incomplete"`
+}
+
+// CreateNoteFromPRRequest optionally overrides the prefilled title and content.
+type CreateNoteFromPRRequest struct {
+	Title   string `json:"title" binding:"max=255"`
+	Content string `json:"content"`
+}
+
+// FieldChange is a field that differs between two versions of a cached item.
+type FieldChange struct {
+	Field  string      `json:"field"`
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+type RefreshPullRequestResponse struct {
+	PullRequest PullRequest   `json:"pull_request"`
+	Changes     []FieldChange `json:"changes"`
+}
+
+// RefreshedLink is the outcome of refreshing one PR linked to a note.
+type RefreshedLink struct {
+	PullRequestID uuid.UUID     `json:"pull_request_id"`
+	Changes       []FieldChange `json:"changes"`
+	Error         string        `json:"error,omitempty"`
+}
+
+type RefreshNoteLinksResponse struct {
+	Note         Note            `json:"note"`
+	PullRequests []RefreshedLink `json:"pull_requests"`
+}
+
+type WebhookResponse struct {
+	DeliveryID string `json:"delivery_id"`
+	Status     string `json:"status"`
+	Summary    string `json:"summary"`
+	Duplicate  bool   `json:"duplicate"`
+}
+
+type RepositoryListItem struct {
+	Repository
+	NoteCount  int64 `json:"note_count"`
+	Accessible bool  `json:"accessible"`
+}
+
+type RepositoriesResponse struct {
+	Repositories []RepositoryListItem `json:"repositories"`
+	Total        int                  `json:"total"`
+	Page         int                  `json:"page"`
+	Limit        int                  `json:"limit"`
+	SyncedAt     *time.Time           `json:"synced_at"`
+}
+
+type RepositorySyncResponse struct {
+	Synced   int       `json:"synced"`
+	SyncedAt time.Time `json:"synced_at"`
 }
 
 type NotesResponse struct {
@@ -106,17 +837,178 @@ type NotesResponse struct {
 	Limit int    `json:"limit"`
 }
 
+type PullRequestListItem struct {
+	PullRequest
+	NoteCount int64 `json:"note_count"`
+}
+
+type PullRequestsResponse struct {
+	PullRequests []PullRequestListItem `json:"pull_requests"`
+	Total        int64                 `json:"total"`
+	Page         int                   `json:"page"`
+	Limit        int                   `json:"limit"`
+}
+
+type PullRequestTimelineResponse struct {
+	PullRequestID uuid.UUID          `json:"pull_request_id"`
+	Events        []PullRequestEvent `json:"events"`
+	Total         int64              `json:"total"`
+	Page          int                `json:"page"`
+	Limit         int                `json:"limit"`
+}
+
+// PullRequestNotesResponse is a cached PR with every note the caller wrote
+// about it.
+type PullRequestNotesResponse struct {
+	PullRequest PullRequest `json:"pull_request"`
+	Notes       []Note      `json:"notes"`
+}
+
 type GithubPullRequest struct {
 	ID     int    `json:"id"`
 	Number int    `json:"number"`
 	Title  string `json:"title"`
 	Body   string `json:"body"`
 	State  string `json:"state"`
+	Draft  bool   `json:"draft"`
+	Merged bool   `json:"merged"`
 	User   struct {
 		Login string `json:"login"`
 	} `json:"user"`
+	Head struct {
+		SHA string `json:"sha"`
+	} `json:"head"`
+	// Base.Repo carries the repository's current owner and name, which
+	// differ from the requested ones when the repository was renamed
+	Base struct {
+		Repo GithubRepository `json:"repo"`
+	} `json:"base"`
+	HTMLURL   string     `json:"html_url"`
+	CreatedAt time.Time  `json:"created_at"`
+	MergedAt  *time.Time `json:"merged_at"`
+}
+
+type GithubIssue struct {
+	ID          int    `json:"id"`
+	Number      int    `json:"number"`
+	Title       string `json:"title"`
+	Body        string `json:"body"`
+	State       string `json:"state"`
+	StateReason string `json:"state_reason"`
+	User        struct {
+		Login string `json:"login"`
+	} `json:"user"`
+	Labels []struct {
+		Name string `json:"name"`
+	} `json:"labels"`
+	Assignees []struct {
+		Login string `json:"login"`
+	} `json:"assignees"`
+	// PullRequest is only present when the issue is actually a pull request
+	PullRequest *struct {
+		URL string `json:"url"`
+	} `json:"pull_request,omitempty"`
+	RepositoryURL string    `json:"repository_url"`
+	HTMLURL       string    `json:"html_url"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type GithubCommit struct {
+	SHA     string `json:"sha"`
+	HTMLURL string `json:"html_url"`
+	Commit  struct {
+		Message string `json:"message"`
+		Author  struct {
+			Name  string    `json:"name"`
+			Email string    `json:"email"`
+			Date  time.Time `json:"date"`
+		} `json:"author"`
+	} `json:"commit"`
+	Author *struct {
+		Login string `json:"login"`
+	} `json:"author"`
+	Parents []struct {
+		SHA string `json:"sha"`
+	} `json:"parents"`
+	Stats struct {
+		Additions int `json:"additions"`
+		Deletions int `json:"deletions"`
+		Total     int `json:"total"`
+	} `json:"stats"`
+	Files []struct {
+		Filename string `json:"filename"`
+	} `json:"files"`
+}
+
+type GithubRepository struct {
+	ID       int64  `json:"id"`
+	Name     string `json:"name"`
+	FullName string `json:"full_name"`
+	Owner    struct {
+		Login string `json:"login"`
+	} `json:"owner"`
+	Private       bool   `json:"private"`
+	Description   string `json:"description"`
+	DefaultBranch string `json:"default_branch"`
+	HTMLURL       string `json:"html_url"`
+}
+
+// GithubWebhookPayload holds the parts of pull_request, pull_request_review
+// and issue_comment webhook payloads that are cached.
+type GithubWebhookPayload struct {
+	Action      string             `json:"action"`
+	Repository  *GithubRepository  `json:"repository"`
+	PullRequest *GithubPullRequest `json:"pull_request"`
+	Issue       *GithubIssue       `json:"issue"`
+	Comment     *GithubComment     `json:"comment"`
+	Review      *GithubReview      `json:"review"`
+}
+
+// GithubSearchIssue is an item returned by the GitHub issue search API.
+type GithubSearchIssue struct {
+	Number        int    `json:"number"`
+	Title         string `json:"title"`
+	State         string `json:"state"`
+	Draft         bool   `json:"draft"`
+	HTMLURL       string `json:"html_url"`
+	RepositoryURL string `json:"repository_url"`
+	User          struct {
+		Login string `json:"login"`
+	} `json:"user"`
+	PullRequest *struct {
+		MergedAt *time.Time `json:"merged_at"`
+	} `json:"pull_request,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type GithubComment struct {
+	ID   int64  `json:"id"`
+	Body string `json:"body"`
+	User struct {
+		Login string `json:"login"`
+	} `json:"user"`
 	HTMLURL   string    `json:"html_url"`
 	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// GithubReviewComment is a comment on a line of a pull request diff.
+type GithubReviewComment struct {
+	GithubComment
+	Path         string `json:"path"`
+	Line         *int   `json:"line"`
+	OriginalLine *int   `json:"original_line"`
+	InReplyToID  *int64 `json:"in_reply_to_id"`
+}
+
+type GithubReview struct {
+	ID      int64  `json:"id"`
+	Body    string `json:"body"`
+	State   string `json:"state"`
+	HTMLURL string `json:"html_url"`
+	User    struct {
+		Login string `json:"login"`
+	} `json:"user"`
 }
 
 // BeforeCreate hooks
@@ -140,3 +1032,151 @@ func (pr *PullRequest) BeforeCreate(tx *gorm.DB) error {
 	}
 	return nil
 }
+
+func (i *Issue) BeforeCreate(tx *gorm.DB) error {
+	if i.ID == uuid.Nil {
+		i.ID = uuid.New()
+	}
+	return nil
+}
+
+func (c *Commit) BeforeCreate(tx *gorm.DB) error {
+	if c.ID == uuid.Nil {
+		c.ID = uuid.New()
+	}
+	return nil
+}
+
+func (c *PRComment) BeforeCreate(tx *gorm.DB) error {
+	if c.ID == uuid.Nil {
+		c.ID = uuid.New()
+	}
+	return nil
+}
+
+func (c *Credential) BeforeCreate(tx *gorm.DB) error {
+	if c.ID == uuid.Nil {
+		c.ID = uuid.New()
+	}
+	return nil
+}
+
+func (r *Repository) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return nil
+}
+
+func (d *WebhookDelivery) BeforeCreate(tx *gorm.DB) error {
+	if d.ID == uuid.Nil {
+		d.ID = uuid.New()
+	}
+	return nil
+}
+
+func (s *NotePRSnapshot) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == uuid.Nil {
+		s.ID = uuid.New()
+	}
+	return nil
+}
+
+func (e *PullRequestEvent) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	return nil
+}
+
+func (t *Tag) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == uuid.Nil {
+		t.ID = uuid.New()
+	}
+	return nil
+}
+
+func (r *NoteRevision) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return nil
+}
+
+func (n *Notebook) BeforeCreate(tx *gorm.DB) error {
+	if n.ID == uuid.Nil {
+		n.ID = uuid.New()
+	}
+	return nil
+}
+
+func (a *RepositoryAccess) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	return nil
+}
+
+// BeforeSave hooks normalize repository identifiers. Code hosts treat owner
+// and repository names case-insensitively, so cached rows store them in lower
+// case to keep "Foo/Bar" and "foo/bar" on the same row.
+func (pr *PullRequest) BeforeSave(tx *gorm.DB) error {
+	pr.Provider = NormalizeIdentifier(pr.Provider)
+	pr.Host = NormalizeIdentifier(pr.Host)
+	pr.RepoOwner = NormalizeIdentifier(pr.RepoOwner)
+	pr.RepoName = NormalizeIdentifier(pr.RepoName)
+	return nil
+}
+
+func (i *Issue) BeforeSave(tx *gorm.DB) error {
+	i.RepoOwner = NormalizeIdentifier(i.RepoOwner)
+	i.RepoName = NormalizeIdentifier(i.RepoName)
+	return nil
+}
+
+func (c *Commit) BeforeSave(tx *gorm.DB) error {
+	c.RepoOwner = NormalizeIdentifier(c.RepoOwner)
+	c.RepoName = NormalizeIdentifier(c.RepoName)
+	return nil
+}
+
+// NormalizeIdentifier returns the stored form of a provider, host, owner or
+// repository name.
+func NormalizeIdentifier(value string) string {
+	return strings.ToLower(strings.TrimSpace(value))
+}
+
+// AfterFind hooks
+func (pr *PullRequest) AfterFind(tx *gorm.DB) error {
+	pr.SourceUnavailable = pr.Unavailable()
+	return nil
+}
+
+func (n *Note) AfterFind(tx *gorm.DB) error {
+	n.UpdateSourceUnavailable()
+	n.CompareSnapshots()
+	return nil
+}
+
+// CompareSnapshots compares each PR snapshot with the linked PR.
+func (n *Note) CompareSnapshots() {
+	for i := range n.PRSnapshots {
+		for j := range n.PullRequests {
+			if n.PullRequests[j].ID == n.PRSnapshots[i].PullRequestID {
+				n.PRSnapshots[i].Compare(&n.PullRequests[j])
+				break
+			}
+		}
+	}
+}
+
+// UpdateSourceUnavailable recomputes SourceUnavailable from the linked PRs.
+func (n *Note) UpdateSourceUnavailable() {
+	n.SourceUnavailable = false
+	for i := range n.PullRequests {
+		n.PullRequests[i].SourceUnavailable = n.PullRequests[i].Unavailable()
+		if n.PullRequests[i].SourceUnavailable {
+			n.SourceUnavailable = true
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github-notes-backend/internal/config"
+	"github-notes-backend/internal/database"
+	"github-notes-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm/clause"
+)
+
+// AccessChecker decides which cached PRs, issues and commits a user may see.
+// The caches are shared between users, so a private item cached by one user
+// must not be shown to another whose credentials cannot read it. Public items
+// are visible to everyone. Access to private repositories is verified against
+// the code host with the user's credential and cached per user and
+// repository.
+type AccessChecker struct {
+	github    *GitHubService
+	ttl       time.Duration
+	deniedTTL time.Duration
+}
+
+func NewAccessChecker(cfg *config.Config) *AccessChecker {
+	return &AccessChecker{
+		github:    NewGitHubService(),
+		ttl:       cfg.RepoAccessTTL,
+		deniedTTL: cfg.RepoAccessDeniedTTL,
+	}
+}
+
+type repositoryKey struct {
+	Provider string
+	Host     string
+	Owner    string
+	Name     string
+}
+
+func repositoryKeyFor(pr *models.PullRequest) repositoryKey {
+	return repositoryKey{Provider: pr.Provider, Host: pr.Host, Owner: pr.RepoOwner, Name: pr.RepoName}
+}
+
+func issueRepositoryKey(issue *models.Issue) repositoryKey {
+	return repositoryKey{Provider: models.ProviderGitHub, Host: issue.Host, Owner: issue.RepoOwner, Name: issue.RepoName}
+}
+
+// Commits are only cached from github.com
+func commitRepositoryKey(commit *models.Commit) repositoryKey {
+	return repositoryKey{Provider: models.ProviderGitHub, Host: models.DefaultGitHubHost, Owner: commit.RepoOwner, Name: commit.RepoName}
+}
+
+// privateItem is a cached private item whose repository access is checked.
+// fetch reads it from the code host with the user's credential.
+type privateItem struct {
+	ID    uuid.UUID
+	Key   repositoryKey
+	fetch func(client *ProviderClient) error
+}
+
+// CanRead reports whether user may see pr.
+func (a *AccessChecker) CanRead(user *models.User, pr *models.PullRequest) bool {
+	return len(a.Unreadable(user, []*models.PullRequest{pr})) == 0
+}
+
+// CanReadIssue reports whether user may see issue.
+func (a *AccessChecker) CanReadIssue(user *models.User, issue *models.Issue) bool {
+	return len(a.UnreadableIssues(user, []*models.Issue{issue})) == 0
+}
+
+// CanReadCommit reports whether user may see commit.
+func (a *AccessChecker) CanReadCommit(user *models.User, commit *models.Commit) bool {
+	return len(a.UnreadableCommits(user, []*models.Commit{commit})) == 0
+}
+
+// Unreadable returns the ids of the PRs user may not see. A repository
+// without a cached answer is checked by fetching one of its PRs with the
+// user's credential. When the code host gives no definite answer, e.g.
+// because it is unreachable or rate limited, the PRs are withheld for this
+// call only.
+func (a *AccessChecker) Unreadable(user *models.User, prs []*models.PullRequest) map[uuid.UUID]bool {
+	var items []privateItem
+	for _, pr := range prs {
+		if !pr.Private {
+			continue
+		}
+		ref := ChangeRequestRef{Provider: pr.Provider, Host: pr.Host, Owner: pr.RepoOwner, Repo: pr.RepoName, Number: pr.Number}
+		items = append(items, privateItem{ID: pr.ID, Key: repositoryKeyFor(pr), fetch: func(client *ProviderClient) error {
+			_, err := client.Provider.FetchChangeRequest(ref, client.Token)
+			return err
+		}})
+	}
+	return a.unreadable(user, items)
+}
+
+// UnreadableIssues returns the ids of the issues user may not see, checking
+// repositories the same way as Unreadable.
+func (a *AccessChecker) UnreadableIssues(user *models.User, issues []*models.Issue) map[uuid.UUID]bool {
+	var items []privateItem
+	for _, issue := range issues {
+		if !issue.Private {
+			continue
+		}
+		owner, repo, number := issue.RepoOwner, issue.RepoName, issue.Number
+		items = append(items, privateItem{ID: issue.ID, Key: issueRepositoryKey(issue), fetch: func(client *ProviderClient) error {
+			github, err := githubServiceFor(client)
+			if err != nil {
+				return err
+			}
+			_, err = github.GetIssue(owner, repo, number, client.Token)
+			return err
+		}})
+	}
+	return a.unreadable(user, items)
+}
+
+// UnreadableCommits returns the ids of the commits user may not see, checking
+// repositories the same way as Unreadable.
+func (a *AccessChecker) UnreadableCommits(user *models.User, commits []*models.Commit) map[uuid.UUID]bool {
+	var items []privateItem
+	for _, commit := range commits {
+		if !commit.Private {
+			continue
+		}
+		owner, repo, sha := commit.RepoOwner, commit.RepoName, commit.SHA
+		items = append(items, privateItem{ID: commit.ID, Key: commitRepositoryKey(commit), fetch: func(client *ProviderClient) error {
+			github, err := githubServiceFor(client)
+			if err != nil {
+				return err
+			}
+			_, err = github.GetCommit(owner, repo, sha, client.Token)
+			return err
+		}})
+	}
+	return a.unreadable(user, items)
+}
+
+func (a *AccessChecker) unreadable(user *models.User, items []privateItem) map[uuid.UUID]bool {
+	unreadable := make(map[uuid.UUID]bool)
+
+	byRepository := make(map[repositoryKey][]privateItem)
+	for _, item := range items {
+		byRepository[item.Key] = append(byRepository[item.Key], item)
+	}
+	if len(byRepository) == 0 {
+		return unreadable
+	}
+
+	cached := a.cachedAccess(user.ID, byRepository)
+	for key, repoItems := range byRepository {
+		granted, ok := cached[key]
+		if !ok {
+			granted = a.verify(user, repoItems[0])
+		}
+		if !granted {
+			for _, item := range repoItems {
+				unreadable[item.ID] = true
+			}
+		}
+	}
+	return unreadable
+}
+
+// Grant records that user just read pr from its code host.
+func (a *AccessChecker) Grant(userID uuid.UUID, pr *models.PullRequest) {
+	if pr.Private {
+		a.record(userID, repositoryKeyFor(pr), true)
+	}
+}
+
+// GrantIssue records that user just read issue from its code host.
+func (a *AccessChecker) GrantIssue(userID uuid.UUID, issue *models.Issue) {
+	if issue.Private {
+		a.record(userID, issueRepositoryKey(issue), true)
+	}
+}
+
+// GrantCommit records that user just read commit from its code host.
+func (a *AccessChecker) GrantCommit(userID uuid.UUID, commit *models.Commit) {
+	if commit.Private {
+		a.record(userID, commitRepositoryKey(commit), true)
+	}
+}
+
+// githubServiceFor returns the GitHub API behind client. Issues and commits
+// are only cached from GitHub.
+func githubServiceFor(client *ProviderClient) (*GitHubService, error) {
+	github, ok := client.Provider.(*GitHubProvider)
+	if !ok {
+		return nil, fmt.Errorf("%s does not serve issues or commits", client.Provider.Name())
+	}
+	return github.Service(), nil
+}
+
+// cachedAccess loads the unexpired answers for the user and repositories.
+func (a *AccessChecker) cachedAccess(userID uuid.UUID, repositories map[repositoryKey][]privateItem) map[repositoryKey]bool {
+	keys := make([][]interface{}, 0, len(repositories))
+	for key := range repositories {
+		keys = append(keys, []interface{}{key.Provider, key.Host, key.Owner, key.Name})
+	}
+
+	var rows []models.RepositoryAccess
+	database.DB.Where("user_id = ? AND expires_at > ?", userID, time.Now()).
+		Where("(provider, host, repo_owner, repo_name) IN ?", keys).
+		Find(&rows)
+
+	cached := make(map[repositoryKey]bool, len(rows))
+	for _, row := range rows {
+		cached[repositoryKey{Provider: row.Provider, Host: row.Host, Owner: row.RepoOwner, Name: row.RepoName}] = row.Granted
+	}
+	return cached
+}
+
+// verify fetches item with the user's credential and caches the outcome
+// when it is a definite yes or no.
+func (a *AccessChecker) verify(user *models.User, item privateItem) bool {
+	client, err := ProviderForUser(a.github, user, item.Key.Provider, item.Key.Host)
+	if err != nil {
+		if _, ok := err.(*CredentialError); ok {
+			a.record(user.ID, item.Key, false)
+		}
+		return false
+	}
+
+	if err := item.fetch(client); err != nil {
+		if fetchStatusForError(err) != "" {
+			a.record(user.ID, item.Key, false)
+		}
+		return false
+	}
+
+	a.record(user.ID, item.Key, true)
+	return true
+}
+
+func (a *AccessChecker) record(userID uuid.UUID, key repositoryKey, granted bool) {
+	ttl := a.ttl
+	if !granted {
+		ttl = a.deniedTTL
+	}
+
+	database.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "provider"}, {Name: "host"}, {Name: "repo_owner"}, {Name: "repo_name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"granted", "expires_at", "updated_at"}),
+	}).Create(&models.RepositoryAccess{
+		UserID:    userID,
+		Provider:  key.Provider,
+		Host:      key.Host,
+		RepoOwner: key.Owner,
+		RepoName:  key.Name,
+		Granted:   granted,
+		ExpiresAt: time.Now().Add(ttl),
+	})
+}
+
+// ForgetRepositoryAccess drops the user's cached access answers for a code
+// host, so they are verified again with a changed credential.
+func ForgetRepositoryAccess(userID uuid.UUID, provider, host string) error {
+	return database.DB.Where("user_id = ? AND provider = ? AND host = ?", userID, provider, host).
+		Delete(&models.RepositoryAccess{}).Error
+}
@@ -0,0 +1,48 @@
+package services
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// lookupIP resolves host names for CheckCodeHostURL; tests replace it.
+var lookupIP = net.LookupIP
+
+// CheckCodeHostURL rejects base URLs the server should not send a user's
+// token to: anything but https, and hosts resolving to loopback, private or
+// link-local addresses such as the cloud metadata endpoint. Hosts in allowed,
+// with or without port, are exempt from both rules.
+func CheckCodeHostURL(rawURL string, allowed []string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Host == "" {
+		return fmt.Errorf("invalid URL %q", rawURL)
+	}
+
+	host := strings.ToLower(parsed.Host)
+	hostname := strings.ToLower(parsed.Hostname())
+	for _, entry := range allowed {
+		if entry = strings.ToLower(entry); entry == host || entry == hostname {
+			return nil
+		}
+	}
+
+	if parsed.Scheme != "https" {
+		return fmt.Errorf("base URL must use https")
+	}
+
+	ips := []net.IP{net.ParseIP(hostname)}
+	if ips[0] == nil {
+		if ips, err = lookupIP(hostname); err != nil || len(ips) == 0 {
+			return fmt.Errorf("cannot resolve host %s", hostname)
+		}
+	}
+	for _, ip := range ips {
+		if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+			ip.IsUnspecified() {
+			return fmt.Errorf("host %s resolves to an internal address", hostname)
+		}
+	}
+	return nil
+}
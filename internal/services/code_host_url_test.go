@@ -0,0 +1,53 @@
+package services
+
+import (
+	"fmt"
+	"net"
+	"testing"
+)
+
+func TestCheckCodeHostURL(t *testing.T) {
+	resolved := map[string][]net.IP{
+		"gitlab.example.com":   {net.ParseIP("203.0.113.10")},
+		"metadata.example.com": {net.ParseIP("169.254.169.254")},
+		"mixed.example.com":    {net.ParseIP("203.0.113.11"), net.ParseIP("10.0.0.5")},
+	}
+	lookupIP = func(host string) ([]net.IP, error) {
+		if ips, ok := resolved[host]; ok {
+			return ips, nil
+		}
+		return nil, fmt.Errorf("no such host")
+	}
+	defer func() { lookupIP = net.LookupIP }()
+
+	tests := []struct {
+		name    string
+		url     string
+		allowed []string
+		wantErr bool
+	}{
+		{"public https host", "https://gitlab.example.com", nil, false},
+		{"public host under a subpath", "https://gitlab.example.com/gitlab", nil, false},
+		{"plain http", "http://gitlab.example.com", nil, true},
+		{"loopback", "https://127.0.0.1:8080", nil, true},
+		{"IPv6 loopback", "https://[::1]", nil, true},
+		{"private address", "https://192.168.1.20", nil, true},
+		{"unspecified address", "https://0.0.0.0", nil, true},
+		{"metadata endpoint", "https://169.254.169.254/latest/meta-data", nil, true},
+		{"name resolving to link-local", "https://metadata.example.com", nil, true},
+		{"one private address among several", "https://mixed.example.com", nil, true},
+		{"unresolvable host", "https://unknown.example.com", nil, true},
+		{"not a URL", "gitlab.example.com", nil, true},
+		{"allowed internal host", "http://10.0.0.5:3000", []string{"10.0.0.5"}, false},
+		{"allowed host with port", "https://mixed.example.com:8443", []string{"MIXED.example.com:8443"}, false},
+		{"allowlist for another host", "https://192.168.1.20", []string{"10.0.0.5"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckCodeHostURL(tt.url, tt.allowed)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CheckCodeHostURL(%q) error = %v, want error %v", tt.url, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package services
+
+import (
+	"github-notes-backend/internal/database"
+	"github-notes-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm/clause"
+)
+
+// commitKey is the unique key of the commit cache.
+var commitKey = []clause.Column{{Name: "repo_owner"}, {Name: "repo_name"}, {Name: "sha"}}
+
+// UpsertCommit stores commit, or refreshes the cached row with the same
+// repository and SHA if another request got there first. On return commit.ID
+// is the id of the stored row.
+func UpsertCommit(commit *models.Commit) error {
+	if commit.ID == uuid.Nil {
+		commit.ID = uuid.New()
+	}
+
+	updates := clause.AssignmentColumns([]string{"message", "author", "author_email", "authored_at", "parents",
+		"additions", "deletions", "changed_files", "associated_prs", "url", "private", "updated_at"})
+	if err := database.DB.Clauses(clause.OnConflict{Columns: commitKey, DoUpdates: updates}).Create(commit).Error; err != nil {
+		return err
+	}
+
+	// The insert may have turned into an update of an existing row
+	var stored models.Commit
+	if err := database.DB.Where("repo_owner = ? AND repo_name = ? AND sha = ?",
+		commit.RepoOwner, commit.RepoName, commit.SHA).First(&stored).Error; err != nil {
+		return err
+	}
+	*commit = stored
+	return nil
+}
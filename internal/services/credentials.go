@@ -0,0 +1,70 @@
+package services
+
+import (
+	"fmt"
+
+	"github-notes-backend/internal/database"
+	"github-notes-backend/internal/models"
+)
+
+// ProviderClient is a code host client paired with a user's token for it.
+type ProviderClient struct {
+	Provider CodeHostProvider
+	Token    string
+	Host     string
+}
+
+// CredentialError is returned by ProviderForUser when the user has no usable
+// token for the requested code host.
+type CredentialError struct {
+	Message string
+}
+
+func (e *CredentialError) Error() string {
+	return e.Message
+}
+
+// ProviderForUser returns the client and token to use for the user on a code
+// host. github.com uses the token from the profile; other hosts, including
+// GitHub Enterprise Server, use the matching registered credential. An empty
+// host is accepted when the user has exactly one credential for the provider.
+func ProviderForUser(github *GitHubService, user *models.User, provider, host string) (*ProviderClient, error) {
+	if provider == "" {
+		provider = models.ProviderGitHub
+	}
+	if models.IsGitHubDotCom(provider, host) {
+		if user.GithubToken == "" {
+			return nil, &CredentialError{"GitHub token is required to fetch PR information. Please update your profile first."}
+		}
+		return &ProviderClient{
+			Provider: NewGitHubProvider(github),
+			Token:    user.GithubToken,
+			Host:     models.DefaultGitHubHost,
+		}, nil
+	}
+
+	var credentials []models.Credential
+	query := database.DB.Where("user_id = ? AND provider = ?", user.ID, provider)
+	if host != "" {
+		query = query.Where("host = ?", host)
+	}
+	if err := query.Limit(2).Find(&credentials).Error; err != nil {
+		return nil, fmt.Errorf("failed to load credentials: %w", err)
+	}
+
+	switch len(credentials) {
+	case 0:
+		return nil, &CredentialError{fmt.Sprintf("No %s credential registered. Please add one in your profile first.", provider)}
+	case 1:
+	default:
+		return nil, &CredentialError{fmt.Sprintf("Multiple %s credentials registered. Please specify the host.", provider)}
+	}
+
+	credential := credentials[0]
+	client, err := NewProvider(provider, credential.BaseURL)
+	if err != nil {
+		return nil, &CredentialError{err.Error()}
+	}
+
+	return &ProviderClient{Provider: client, Token: credential.Token, Host: credential.Host}, nil
+}
@@ -0,0 +1,240 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github-notes-backend/internal/models"
+)
+
+// maxEditDistance bounds the work done diffing two texts. Texts further apart
+// than this are reported as their differing middle being replaced wholesale.
+const maxEditDistance = 1000
+
+// diffContext is the number of unchanged lines around each unified diff hunk.
+const diffContext = 3
+
+// wordPattern splits text into words, whitespace runs and single punctuation
+// characters, so the tokens concatenate back into the original text.
+var wordPattern = regexp.MustCompile(`[\p{L}\p{N}_]+|\s+|[^\p{L}\p{N}_\s]`)
+
+// edit is one step of an edit script. A and B are the positions in the old
+// and new token lists the step applies at.
+type edit struct {
+	Op string
+	A  int
+	B  int
+}
+
+// DiffWords compares two texts word by word. Concatenating the chunks'
+// text, leaving out deletions, gives b; leaving out insertions gives a.
+func DiffWords(a, b string) []models.DiffChunk {
+	aTokens := wordPattern.FindAllString(a, -1)
+	bTokens := wordPattern.FindAllString(b, -1)
+
+	chunks := []models.DiffChunk{}
+	for _, e := range diffTokens(aTokens, bTokens) {
+		text := ""
+		if e.Op == models.DiffInsert {
+			text = bTokens[e.B]
+		} else {
+			text = aTokens[e.A]
+		}
+		if n := len(chunks); n > 0 && chunks[n-1].Op == e.Op {
+			chunks[n-1].Text += text
+			continue
+		}
+		chunks = append(chunks, models.DiffChunk{Op: e.Op, Text: text})
+	}
+	return chunks
+}
+
+// UnifiedDiff compares two texts line by line in unified diff format, with
+// fromLabel and toLabel naming the two sides. It returns "" when the texts
+// have the same lines.
+func UnifiedDiff(a, b, fromLabel, toLabel string) string {
+	aLines := splitLines(a)
+	bLines := splitLines(b)
+	edits := diffTokens(aLines, bLines)
+
+	var out strings.Builder
+	for i := 0; i < len(edits); {
+		if edits[i].Op == models.DiffEqual {
+			i++
+			continue
+		}
+
+		// A hunk runs until the next change is too far away to share context
+		start := max(i-diffContext, 0)
+		end := i
+		for end < len(edits) {
+			if edits[end].Op != models.DiffEqual {
+				end++
+				continue
+			}
+			next := end
+			for next < len(edits) && edits[next].Op == models.DiffEqual {
+				next++
+			}
+			if next == len(edits) || next-end > 2*diffContext {
+				end = min(end+diffContext, next)
+				break
+			}
+			end = next
+		}
+
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromLabel, toLabel)
+		}
+		writeHunk(&out, edits[start:end], aLines, bLines)
+		i = end
+	}
+	return out.String()
+}
+
+func writeHunk(out *strings.Builder, edits []edit, a, b []string) {
+	aStart, bStart := edits[0].A, edits[0].B
+	aCount, bCount := 0, 0
+	for _, e := range edits {
+		if e.Op != models.DiffInsert {
+			aCount++
+		}
+		if e.Op != models.DiffDelete {
+			bCount++
+		}
+	}
+	// An empty side is numbered by the line before it
+	if aCount > 0 {
+		aStart++
+	}
+	if bCount > 0 {
+		bStart++
+	}
+	fmt.Fprintf(out, "@@ -%d,%d +%d,%d @@\n", aStart, aCount, bStart, bCount)
+
+	for _, e := range edits {
+		switch e.Op {
+		case models.DiffEqual:
+			out.WriteString(" " + a[e.A] + "\n")
+		case models.DiffDelete:
+			out.WriteString("-" + a[e.A] + "\n")
+		case models.DiffInsert:
+			out.WriteString("+" + b[e.B] + "\n")
+		}
+	}
+}
+
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// diffTokens returns the shortest edit script turning a into b, using Myers'
+// algorithm on what is left after the common prefix and suffix.
+func diffTokens(a, b []string) []edit {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	edits := make([]edit, 0, len(a)+len(b))
+	for i := 0; i < prefix; i++ {
+		edits = append(edits, edit{models.DiffEqual, i, i})
+	}
+	edits = append(edits, myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix], prefix)...)
+	for i := suffix; i > 0; i-- {
+		edits = append(edits, edit{models.DiffEqual, len(a) - i, len(b) - i})
+	}
+	return edits
+}
+
+// myers diffs a and b, offsetting the positions in the result by offset.
+func myers(a, b []string, offset int) []edit {
+	n, m := len(a), len(b)
+	limit := min(n+m, maxEditDistance)
+
+	// v[limit+1+k] is the furthest x reached on diagonal k = x - y; trace
+	// keeps v as it was before each round d, for diagonals -d..d
+	v := make([]int, 2*limit+3)
+	center := limit + 1
+	var trace [][]int
+	found := false
+	for d := 0; d <= limit && !found; d++ {
+		trace = append(trace, append([]int(nil), v[center-d:center+d+1]...))
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[center+k-1] < v[center+k+1]) {
+				x = v[center+k+1]
+			} else {
+				x = v[center+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[center+k] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+	}
+
+	if !found {
+		edits := make([]edit, 0, n+m)
+		for i := 0; i < n; i++ {
+			edits = append(edits, edit{models.DiffDelete, offset + i, offset})
+		}
+		for j := 0; j < m; j++ {
+			edits = append(edits, edit{models.DiffInsert, offset + n, offset + j})
+		}
+		return edits
+	}
+
+	// Walk the trace back from the end to recover the script
+	var reversed []edit
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		k := x - y
+		prevX, prevY := 0, 0
+		if d > 0 {
+			prev := trace[d]
+			prevK := k - 1
+			if k == -d || (k != d && prev[d+k-1] < prev[d+k+1]) {
+				prevK = k + 1
+			}
+			prevX = prev[d+prevK]
+			prevY = prevX - prevK
+		}
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			reversed = append(reversed, edit{models.DiffEqual, offset + x, offset + y})
+		}
+		if d > 0 {
+			if x == prevX {
+				y--
+				reversed = append(reversed, edit{models.DiffInsert, offset + x, offset + y})
+			} else {
+				x--
+				reversed = append(reversed, edit{models.DiffDelete, offset + x, offset + y})
+			}
+		}
+	}
+
+	edits := make([]edit, len(reversed))
+	for i, e := range reversed {
+		edits[len(reversed)-1-i] = e
+	}
+	return edits
+}
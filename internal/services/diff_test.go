@@ -0,0 +1,215 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github-notes-backend/internal/models"
+)
+
+// numberedLines returns "line 1\n" to "line n\n", with the lines in changed
+// replaced.
+func numberedLines(n int, changed ...int) string {
+	var out strings.Builder
+	for i := 1; i <= n; i++ {
+		line := fmt.Sprintf("line %d", i)
+		for _, c := range changed {
+			if c == i {
+				line = fmt.Sprintf("changed %d", i)
+			}
+		}
+		out.WriteString(line + "\n")
+	}
+	return out.String()
+}
+
+// checkEdits fails unless edits turns a into b, with every position in
+// order.
+func checkEdits(t *testing.T, edits []edit, a, b []string) {
+	t.Helper()
+
+	var fromA, fromB []string
+	for _, e := range edits {
+		switch e.Op {
+		case models.DiffEqual:
+			if a[e.A] != b[e.B] {
+				t.Fatalf("equal edit at %d/%d joins %q and %q", e.A, e.B, a[e.A], b[e.B])
+			}
+			fromA = append(fromA, a[e.A])
+			fromB = append(fromB, b[e.B])
+		case models.DiffDelete:
+			fromA = append(fromA, a[e.A])
+		case models.DiffInsert:
+			fromB = append(fromB, b[e.B])
+		}
+	}
+	if strings.Join(fromA, "\x00") != strings.Join(a, "\x00") || strings.Join(fromB, "\x00") != strings.Join(b, "\x00") {
+		t.Fatalf("edit script does not turn a into b: %v", edits)
+	}
+}
+
+func TestDiffTokens(t *testing.T) {
+	tests := []struct {
+		name      string
+		a, b      string
+		wantEdits int
+	}{
+		{"both empty", "", "", 0},
+		{"empty old side", "", "a b c", 0},
+		{"empty new side", "a b c", "", 0},
+		{"identical", "a b c", "a b c", 0},
+		{"one replaced", "a b c", "a x c", 2},
+		{"insert in the middle", "a b c", "a b x c", 1},
+		{"shifted", "a b c d", "b c d e", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := strings.Fields(tt.a), strings.Fields(tt.b)
+			edits := diffTokens(a, b)
+			checkEdits(t, edits, a, b)
+
+			changes := 0
+			for _, e := range edits {
+				if e.Op != models.DiffEqual {
+					changes++
+				}
+			}
+			want := tt.wantEdits
+			if len(a) == 0 || len(b) == 0 {
+				want = len(a) + len(b)
+			}
+			if changes != want {
+				t.Errorf("%d changes, want %d: %v", changes, want, edits)
+			}
+		})
+	}
+}
+
+func TestDiffTokensEditDistanceFallback(t *testing.T) {
+	// Every other token differs, so the shortest script is longer than
+	// maxEditDistance and the middle is replaced wholesale
+	var a, b []string
+	for i := 0; i < maxEditDistance/2+100; i++ {
+		a = append(a, fmt.Sprintf("same%d", i), fmt.Sprintf("old%d", i))
+		b = append(b, fmt.Sprintf("same%d", i), fmt.Sprintf("new%d", i))
+	}
+
+	edits := diffTokens(a, b)
+	checkEdits(t, edits, a, b)
+
+	equal := 0
+	for _, e := range edits {
+		if e.Op == models.DiffEqual {
+			equal++
+		}
+	}
+	if equal != 1 {
+		t.Errorf("%d equal tokens, want only the common prefix", equal)
+	}
+	if len(edits) != 1+2*(len(a)-1) {
+		t.Errorf("%d edits, want %d", len(edits), 1+2*(len(a)-1))
+	}
+}
+
+func TestDiffWords(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []models.DiffChunk
+	}{
+		{"both empty", "", "", []models.DiffChunk{}},
+		{"empty old side", "", "new text", []models.DiffChunk{{Op: models.DiffInsert, Text: "new text"}}},
+		{"empty new side", "old text", "", []models.DiffChunk{{Op: models.DiffDelete, Text: "old text"}}},
+		{"identical", "same text.", "same text.", []models.DiffChunk{{Op: models.DiffEqual, Text: "same text."}}},
+		{
+			"word replaced",
+			"the quick fox",
+			"the slow fox",
+			[]models.DiffChunk{
+				{Op: models.DiffEqual, Text: "the "},
+				{Op: models.DiffDelete, Text: "quick"},
+				{Op: models.DiffInsert, Text: "slow"},
+				{Op: models.DiffEqual, Text: " fox"},
+			},
+		},
+		{
+			"punctuation is its own token",
+			"Done.",
+			"Done!",
+			[]models.DiffChunk{
+				{Op: models.DiffEqual, Text: "Done"},
+				{Op: models.DiffDelete, Text: "."},
+				{Op: models.DiffInsert, Text: "!"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DiffWords(tt.a, tt.b)
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("DiffWords(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{"identical", "a\nb\n", "a\nb\n", ""},
+		{"trailing newline only", "a\nb", "a\nb\n", ""},
+		{"empty old side", "", "a\nb\n", "--- old\n+++ new\n@@ -0,0 +1,2 @@\n+a\n+b\n"},
+		{"empty new side", "a\nb\n", "", "--- old\n+++ new\n@@ -1,2 +0,0 @@\n-a\n-b\n"},
+		{
+			"context is cut at the edges",
+			numberedLines(4),
+			numberedLines(4, 2),
+			"--- old\n+++ new\n@@ -1,4 +1,4 @@\n line 1\n-line 2\n+changed 2\n line 3\n line 4\n",
+		},
+		{
+			"pure insertion",
+			numberedLines(8),
+			strings.Replace(numberedLines(8), "line 4\n", "line 4\nadded\n", 1),
+			"--- old\n+++ new\n@@ -2,6 +2,7 @@\n line 2\n line 3\n line 4\n+added\n line 5\n line 6\n line 7\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := UnifiedDiff(tt.a, tt.b, "old", "new"); got != tt.want {
+				t.Errorf("UnifiedDiff =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnifiedDiffHunks(t *testing.T) {
+	tests := []struct {
+		name    string
+		changed []int
+		want    []string
+	}{
+		{"one change", []int{10}, []string{"@@ -7,7 +7,7 @@"}},
+		{"changes 2×context apart share a hunk", []int{5, 12}, []string{"@@ -2,14 +2,14 @@"}},
+		{"changes further apart split", []int{5, 13}, []string{"@@ -2,7 +2,7 @@", "@@ -10,7 +10,7 @@"}},
+		{"first and last line", []int{1, 20}, []string{"@@ -1,4 +1,4 @@", "@@ -17,4 +17,4 @@"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff := UnifiedDiff(numberedLines(20), numberedLines(20, tt.changed...), "old", "new")
+
+			var got []string
+			for _, line := range strings.Split(diff, "\n") {
+				if strings.HasPrefix(line, "@@") {
+					got = append(got, line)
+				}
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("hunks = %q, want %q\n%s", got, tt.want, diff)
+			}
+		})
+	}
+}
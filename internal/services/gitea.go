@@ -0,0 +1,135 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github-notes-backend/internal/models"
+)
+
+// GiteaService talks to the REST API (v1) of a Gitea or Forgejo instance.
+// The API is modelled on GitHub's but differs in paths ("pulls" instead of
+// "pull" in web URLs), authentication and a few response fields.
+type GiteaService struct {
+	restClient
+}
+
+type giteaPullRequest struct {
+	Number   int        `json:"number"`
+	Title    string     `json:"title"`
+	Body     string     `json:"body"`
+	State    string     `json:"state"`
+	Draft    bool       `json:"draft"`
+	Merged   bool       `json:"merged"`
+	MergedAt *time.Time `json:"merged_at"`
+	HTMLURL  string     `json:"html_url"`
+	User     struct {
+		Login string `json:"login"`
+	} `json:"user"`
+	Head struct {
+		SHA string `json:"sha"`
+	} `json:"head"`
+	Base struct {
+		Repo struct {
+			Private bool `json:"private"`
+		} `json:"repo"`
+	} `json:"base"`
+}
+
+func NewGiteaService(baseURL string) *GiteaService {
+	return &GiteaService{
+		restClient: newRESTClient("Gitea", baseURL, func(req *http.Request, token string) {
+			req.Header.Set("Authorization", "token "+token)
+		}),
+	}
+}
+
+func (s *GiteaService) Name() string {
+	return models.ProviderGitea
+}
+
+func (s *GiteaService) FetchChangeRequest(ref ChangeRequestRef, token string) (*models.PullRequest, error) {
+	if ref.Owner == "" || ref.Repo == "" {
+		return nil, fmt.Errorf("repository owner and name are required")
+	}
+	if ref.Number <= 0 {
+		return nil, fmt.Errorf("PR number must be greater than 0")
+	}
+
+	path := fmt.Sprintf("/api/v1/repos/%s/%s/pulls/%d", url.PathEscape(ref.Owner), url.PathEscape(ref.Repo), ref.Number)
+	notFound := fmt.Sprintf("PR #%d not found in repository %s/%s. Please check if the repository exists and the PR number is correct", ref.Number, ref.Owner, ref.Repo)
+
+	var pr giteaPullRequest
+	if err := s.getJSON(path, token, notFound, &pr); err != nil {
+		return nil, err
+	}
+
+	state := pr.State
+	if pr.Merged {
+		state = "merged"
+	}
+
+	// Older Gitea releases have no draft flag and rely on a WIP title prefix
+	draft := pr.Draft || strings.HasPrefix(strings.ToUpper(pr.Title), "WIP:")
+
+	host, _ := HostFromURL(s.baseURL)
+	return &models.PullRequest{
+		Provider:  models.ProviderGitea,
+		Host:      host,
+		Number:    pr.Number,
+		RepoOwner: ref.Owner,
+		RepoName:  ref.Repo,
+		Title:     pr.Title,
+		Body:      pr.Body,
+		Author:    pr.User.Login,
+		State:     state,
+		Draft:     draft,
+		HeadSHA:   pr.Head.SHA,
+		MergedAt:  pr.MergedAt,
+		URL:       pr.HTMLURL,
+		Private:   pr.Base.Repo.Private,
+	}, nil
+}
+
+// The owner and repository are the last two path segments before "pulls", so
+// instances served under a subpath match too.
+var giteaPullURLPattern = regexp.MustCompile(`^(?:/.+)?/([^/]+)/([^/]+)/pulls/(\d+)/?`)
+
+// ParseURL parses pull request URLs such as
+// https://codeberg.org/owner/repo/pulls/12 or
+// https://example.com/gitea/owner/repo/pulls/12.
+func (s *GiteaService) ParseURL(rawURL string) (*ChangeRequestRef, error) {
+	parsed, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || parsed.Host == "" {
+		return nil, fmt.Errorf("not a Gitea pull request URL: %s", rawURL)
+	}
+
+	matches := giteaPullURLPattern.FindStringSubmatch(parsed.Path)
+	if matches == nil {
+		return nil, fmt.Errorf("not a Gitea pull request URL: %s", rawURL)
+	}
+
+	number, _ := strconv.Atoi(matches[3])
+	return &ChangeRequestRef{
+		Provider: models.ProviderGitea,
+		Host:     strings.ToLower(parsed.Host),
+		Owner:    matches[1],
+		Repo:     matches[2],
+		Number:   number,
+	}, nil
+}
+
+func (s *GiteaService) ValidateToken(token string) (string, error) {
+	var user struct {
+		Login string `json:"login"`
+	}
+	if err := s.getJSON("/api/v1/user", token, "Gitea user not found", &user); err != nil {
+		return "", err
+	}
+	return user.Login, nil
+}
@@ -0,0 +1,160 @@
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github-notes-backend/internal/models"
+)
+
+const giteaTestToken = "gitea-token"
+
+// newGiteaTestServer serves the recorded pull request and user responses of
+// a Forgejo instance living under /forge.
+func newGiteaTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	pull, err := os.ReadFile("testdata/gitea_pull.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/forge/api/v1/repos/infra/deploy-tools/pulls/12", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(pull)
+	})
+	mux.HandleFunc("/forge/api/v1/user", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id": 7, "login": "mlee"}`))
+	})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token "+giteaTestToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"message": "user does not exist"}`))
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestGiteaFetchChangeRequest(t *testing.T) {
+	server := newGiteaTestServer(t)
+	gitea := NewGiteaService(server.URL + "/forge/")
+
+	pr, err := gitea.FetchChangeRequest(ChangeRequestRef{Owner: "infra", Repo: "deploy-tools", Number: 12}, giteaTestToken)
+	if err != nil {
+		t.Fatalf("FetchChangeRequest: %v", err)
+	}
+
+	host, _ := HostFromURL(server.URL)
+	if pr.Provider != models.ProviderGitea || pr.Host != host {
+		t.Errorf("provider/host = %s/%s, want %s/%s", pr.Provider, pr.Host, models.ProviderGitea, host)
+	}
+	if pr.Number != 12 || pr.RepoOwner != "infra" || pr.RepoName != "deploy-tools" {
+		t.Errorf("ref = %s/%s#%d, want infra/deploy-tools#12", pr.RepoOwner, pr.RepoName, pr.Number)
+	}
+	if pr.State != "merged" {
+		t.Errorf("state = %q, want merged", pr.State)
+	}
+	if !pr.Draft {
+		t.Error("WIP: title should mark the PR as a draft")
+	}
+	if pr.MergedAt == nil || pr.MergedAt.Format("2006-01-02") != "2024-03-05" {
+		t.Errorf("merged_at = %v, want 2024-03-05", pr.MergedAt)
+	}
+	if pr.Author != "mlee" || pr.HeadSHA != "c4a8e2f6b0d4c8e2a6f0b4d8c2e6a0f4b8d2c6e0" {
+		t.Errorf("author/head = %s/%s", pr.Author, pr.HeadSHA)
+	}
+	if !pr.Private {
+		t.Error("PR of a private repository should be private")
+	}
+	if pr.URL != "https://git.example.com/forge/infra/deploy-tools/pulls/12" {
+		t.Errorf("url = %s", pr.URL)
+	}
+}
+
+func TestGiteaFetchChangeRequestErrors(t *testing.T) {
+	server := newGiteaTestServer(t)
+	gitea := NewGiteaService(server.URL + "/forge")
+
+	tests := []struct {
+		name   string
+		ref    ChangeRequestRef
+		token  string
+		status int
+		fetch  string
+	}{
+		{"missing PR", ChangeRequestRef{Owner: "infra", Repo: "deploy-tools", Number: 13}, giteaTestToken, http.StatusNotFound, models.FetchStatusNotFound},
+		{"bad token", ChangeRequestRef{Owner: "infra", Repo: "deploy-tools", Number: 12}, "expired", http.StatusUnauthorized, models.FetchStatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := gitea.FetchChangeRequest(tt.ref, tt.token)
+			statusErr, ok := err.(*StatusError)
+			if !ok {
+				t.Fatalf("err = %v, want a *StatusError", err)
+			}
+			if statusErr.StatusCode != tt.status {
+				t.Errorf("status = %d, want %d", statusErr.StatusCode, tt.status)
+			}
+			if got := fetchStatusForError(err); got != tt.fetch {
+				t.Errorf("fetch status = %q, want %q", got, tt.fetch)
+			}
+		})
+	}
+}
+
+func TestGiteaParseURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want *ChangeRequestRef
+	}{
+		{"https://codeberg.org/forgejo/forgejo/pulls/4321", &ChangeRequestRef{Provider: models.ProviderGitea, Host: "codeberg.org", Owner: "forgejo", Repo: "forgejo", Number: 4321}},
+		{"https://Git.Example.com/infra/deploy-tools/pulls/12/files", &ChangeRequestRef{Provider: models.ProviderGitea, Host: "git.example.com", Owner: "infra", Repo: "deploy-tools", Number: 12}},
+		{"https://git.example.com/forge/infra/deploy-tools/pulls/12", &ChangeRequestRef{Provider: models.ProviderGitea, Host: "git.example.com", Owner: "infra", Repo: "deploy-tools", Number: 12}},
+		{"https://github.com/owner/repo/pull/1", nil},
+		{"https://git.example.com/infra/deploy-tools/issues/12", nil},
+		{"/infra/deploy-tools/pulls/12", nil},
+	}
+	for _, tt := range tests {
+		got, err := (&GiteaService{}).ParseURL(tt.url)
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("ParseURL(%q) = %+v, want an error", tt.url, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseURL(%q): %v", tt.url, err)
+			continue
+		}
+		if *got != *tt.want {
+			t.Errorf("ParseURL(%q) = %+v, want %+v", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestGiteaValidateToken(t *testing.T) {
+	server := newGiteaTestServer(t)
+	gitea := NewGiteaService(server.URL + "/forge")
+
+	login, err := gitea.ValidateToken(giteaTestToken)
+	if err != nil || login != "mlee" {
+		t.Fatalf("ValidateToken = %q, %v; want mlee", login, err)
+	}
+
+	_, err = gitea.ValidateToken("expired")
+	if statusErr, ok := err.(*StatusError); !ok || statusErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("err = %v, want a 401 *StatusError", err)
+	}
+
+	// An instance without the user endpoint, e.g. a wrong base URL
+	_, err = NewGiteaService(server.URL + "/elsewhere").ValidateToken(giteaTestToken)
+	if !IsNotFound(err) {
+		t.Fatalf("err = %v, want a 404", err)
+	}
+}
@@ -1,16 +1,34 @@
 package services
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github-notes-backend/internal/models"
 )
 
-type GitHubService struct{}
+const defaultGitHubAPIURL = "https://api.github.com"
+
+type GitHubService struct {
+	baseURL string
+	client  *http.Client
+	// onRepositoryMoved is called when GitHub redirects a /repos/{owner}/{name}
+	// request because the repository was renamed or transferred
+	onRepositoryMoved func(oldOwner, oldName string, repositoryID int64, token string)
+}
+
+var (
+	reposPathPattern      = regexp.MustCompile(`^/repos/([^/?]+)/([^/?]+)`)
+	repositoryPathPattern = regexp.MustCompile(`/repositories/(\d+)`)
+)
 
 type GitHubError struct {
 	Message          string `json:"message"`
@@ -19,7 +37,34 @@ type GitHubError struct {
 }
 
 func NewGitHubService() *GitHubService {
-	return &GitHubService{}
+	s := &GitHubService{
+		baseURL: defaultGitHubAPIURL,
+		client:  &http.Client{CheckRedirect: checkRedirect},
+	}
+	s.onRepositoryMoved = s.followRepositoryMove
+	return s
+}
+
+// NewGitHubEnterpriseService returns a client for the GitHub Enterprise Server
+// instance at baseURL, e.g. "https://github.example.com". Repository moves
+// are not followed there; the registry only tracks them on github.com.
+func NewGitHubEnterpriseService(baseURL string) *GitHubService {
+	return &GitHubService{
+		baseURL: strings.TrimRight(baseURL, "/") + "/api/v3",
+		client:  &http.Client{CheckRedirect: checkRedirect},
+	}
+}
+
+// checkRedirect lets reads follow redirects but hands redirected writes back
+// to do, since net/http would replay them as GET without a body.
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if method := via[0].Method; method != http.MethodGet && method != http.MethodHead {
+		return http.ErrUseLastResponse
+	}
+	if len(via) >= 10 {
+		return fmt.Errorf("stopped after 10 redirects")
+	}
+	return nil
 }
 
 func (s *GitHubService) GetPullRequest(owner, repo string, prNumber int, token string) (*models.GithubPullRequest, error) {
@@ -30,18 +75,371 @@ func (s *GitHubService) GetPullRequest(owner, repo string, prNumber int, token s
 	if prNumber <= 0 {
 		return nil, fmt.Errorf("PR number must be greater than 0")
 	}
-	if token == "" {
-		return nil, fmt.Errorf("GitHub token is required")
+
+	var pr models.GithubPullRequest
+	path := fmt.Sprintf("/repos/%s/%s/pulls/%d", owner, repo, prNumber)
+	notFound := fmt.Sprintf("PR #%d not found in repository %s/%s. Please check if the repository exists and the PR number is correct", prNumber, owner, repo)
+	if err := s.getJSON(path, token, notFound, &pr); err != nil {
+		return nil, err
+	}
+	return &pr, nil
+}
+
+func (s *GitHubService) GetIssue(owner, repo string, issueNumber int, token string) (*models.GithubIssue, error) {
+	if owner == "" || repo == "" {
+		return nil, fmt.Errorf("repository owner and name are required")
+	}
+	if issueNumber <= 0 {
+		return nil, fmt.Errorf("issue number must be greater than 0")
+	}
+
+	var issue models.GithubIssue
+	path := fmt.Sprintf("/repos/%s/%s/issues/%d", owner, repo, issueNumber)
+	notFound := fmt.Sprintf("Issue #%d not found in repository %s/%s. Please check if the repository exists and the issue number is correct", issueNumber, owner, repo)
+	if err := s.getJSON(path, token, notFound, &issue); err != nil {
+		return nil, err
+	}
+	return &issue, nil
+}
+
+// GetCommit fetches a commit by full or abbreviated SHA. GitHub resolves
+// abbreviated SHAs, so the returned commit always carries the full SHA.
+func (s *GitHubService) GetCommit(owner, repo, sha string, token string) (*models.GithubCommit, error) {
+	if owner == "" || repo == "" {
+		return nil, fmt.Errorf("repository owner and name are required")
+	}
+	if sha == "" {
+		return nil, fmt.Errorf("commit SHA is required")
+	}
+
+	var commit models.GithubCommit
+	path := fmt.Sprintf("/repos/%s/%s/commits/%s", owner, repo, sha)
+	notFound := fmt.Sprintf("Commit %s not found in repository %s/%s. Please check if the repository exists and the SHA is correct", sha, owner, repo)
+	if err := s.getJSON(path, token, notFound, &commit); err != nil {
+		return nil, err
+	}
+	return &commit, nil
+}
+
+// GetCommitPullRequests lists the pull requests associated with a commit.
+func (s *GitHubService) GetCommitPullRequests(owner, repo, sha string, token string) ([]models.GithubPullRequest, error) {
+	var prs []models.GithubPullRequest
+	path := fmt.Sprintf("/repos/%s/%s/commits/%s/pulls", owner, repo, sha)
+	notFound := fmt.Sprintf("Commit %s not found in repository %s/%s", sha, owner, repo)
+	if err := s.getJSON(path, token, notFound, &prs); err != nil {
+		return nil, err
+	}
+	return prs, nil
+}
+
+// CreateIssueComment posts a comment on an issue or pull request conversation.
+func (s *GitHubService) CreateIssueComment(owner, repo string, number int, body string, token string) (*models.GithubComment, error) {
+	var comment models.GithubComment
+	path := fmt.Sprintf("/repos/%s/%s/issues/%d/comments", owner, repo, number)
+	notFound := fmt.Sprintf("#%d not found in repository %s/%s", number, owner, repo)
+	if err := s.sendJSON("POST", path, token, notFound, map[string]string{"body": body}, &comment); err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+// UpdateIssueComment replaces the body of an existing issue comment.
+func (s *GitHubService) UpdateIssueComment(owner, repo string, commentID int64, body string, token string) (*models.GithubComment, error) {
+	var comment models.GithubComment
+	path := fmt.Sprintf("/repos/%s/%s/issues/comments/%d", owner, repo, commentID)
+	notFound := fmt.Sprintf("Comment %d not found in repository %s/%s", commentID, owner, repo)
+	if err := s.sendJSON("PATCH", path, token, notFound, map[string]string{"body": body}, &comment); err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+// CreatePendingReview starts a pending review on a pull request. Omitting the
+// event leaves the review in the PENDING state until the author submits it.
+func (s *GitHubService) CreatePendingReview(owner, repo string, prNumber int, body string, token string) (*models.GithubReview, error) {
+	var review models.GithubReview
+	path := fmt.Sprintf("/repos/%s/%s/pulls/%d/reviews", owner, repo, prNumber)
+	notFound := fmt.Sprintf("PR #%d not found in repository %s/%s", prNumber, owner, repo)
+	if err := s.sendJSON("POST", path, token, notFound, map[string]string{"body": body}, &review); err != nil {
+		return nil, err
+	}
+	return &review, nil
+}
+
+// UpdateReview replaces the body of an existing pull request review.
+func (s *GitHubService) UpdateReview(owner, repo string, prNumber int, reviewID int64, body string, token string) (*models.GithubReview, error) {
+	var review models.GithubReview
+	path := fmt.Sprintf("/repos/%s/%s/pulls/%d/reviews/%d", owner, repo, prNumber, reviewID)
+	notFound := fmt.Sprintf("Review %d not found on PR #%d in repository %s/%s", reviewID, prNumber, owner, repo)
+	if err := s.sendJSON("PUT", path, token, notFound, map[string]string{"body": body}, &review); err != nil {
+		return nil, err
+	}
+	return &review, nil
+}
+
+// ListIssueComments returns the conversation comments on an issue or pull
+// request, optionally only those updated at or after since.
+func (s *GitHubService) ListIssueComments(owner, repo string, number int, since *time.Time, token string) ([]models.GithubComment, error) {
+	var all []models.GithubComment
+	path := fmt.Sprintf("/repos/%s/%s/issues/%d/comments", owner, repo, number)
+	notFound := fmt.Sprintf("#%d not found in repository %s/%s", number, owner, repo)
+	err := s.listPages(path, since, token, notFound, func(body []byte) (int, error) {
+		var page []models.GithubComment
+		if err := json.Unmarshal(body, &page); err != nil {
+			return 0, err
+		}
+		all = append(all, page...)
+		return len(page), nil
+	})
+	return all, err
+}
+
+// ListReviewComments returns the diff comments on a pull request, optionally
+// only those updated at or after since.
+func (s *GitHubService) ListReviewComments(owner, repo string, prNumber int, since *time.Time, token string) ([]models.GithubReviewComment, error) {
+	var all []models.GithubReviewComment
+	path := fmt.Sprintf("/repos/%s/%s/pulls/%d/comments", owner, repo, prNumber)
+	notFound := fmt.Sprintf("PR #%d not found in repository %s/%s", prNumber, owner, repo)
+	err := s.listPages(path, since, token, notFound, func(body []byte) (int, error) {
+		var page []models.GithubReviewComment
+		if err := json.Unmarshal(body, &page); err != nil {
+			return 0, err
+		}
+		all = append(all, page...)
+		return len(page), nil
+	})
+	return all, err
+}
+
+// listPages walks a paginated list endpoint, handing each page body to
+// collect until a page comes back short.
+func (s *GitHubService) listPages(path string, since *time.Time, token, notFound string, collect func(body []byte) (int, error)) error {
+	const perPage = 100
+
+	for page := 1; ; page++ {
+		query := url.Values{}
+		query.Set("per_page", strconv.Itoa(perPage))
+		query.Set("page", strconv.Itoa(page))
+		if since != nil {
+			query.Set("since", since.UTC().Format(time.RFC3339))
+		}
+
+		separator := "?"
+		if strings.Contains(path, "?") {
+			separator = "&"
+		}
+
+		resp, body, err := s.do("GET", path+separator+query.Encode(), token, nil)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return statusError(resp.StatusCode, body, notFound)
+		}
+
+		count, err := collect(body)
+		if err != nil {
+			return fmt.Errorf("failed to decode GitHub response: %w", err)
+		}
+		if count < perPage {
+			return nil
+		}
+	}
+}
+
+// GetRepositoryByID fetches a repository by its stable GitHub id, which keeps
+// working after the repository is renamed or transferred.
+func (s *GitHubService) GetRepositoryByID(id int64, token string) (*models.GithubRepository, error) {
+	var repository models.GithubRepository
+	path := fmt.Sprintf("/repositories/%d", id)
+	notFound := fmt.Sprintf("Repository with id %d not found", id)
+	if err := s.getJSON(path, token, notFound, &repository); err != nil {
+		return nil, err
+	}
+	return &repository, nil
+}
+
+func (s *GitHubService) GetRepository(owner, repo string, token string) (*models.GithubRepository, error) {
+	if owner == "" || repo == "" {
+		return nil, fmt.Errorf("repository owner and name are required")
+	}
+
+	var repository models.GithubRepository
+	path := fmt.Sprintf("/repos/%s/%s", owner, repo)
+	notFound := fmt.Sprintf("Repository %s/%s not found", owner, repo)
+	if err := s.getJSON(path, token, notFound, &repository); err != nil {
+		return nil, err
+	}
+	return &repository, nil
+}
+
+// IsPrivateRepository reports whether a repository is private. Repositories
+// whose visibility cannot be fetched are treated as private.
+func (s *GitHubService) IsPrivateRepository(owner, repo string, token string) bool {
+	repository, err := s.GetRepository(owner, repo, token)
+	return err != nil || repository.Private
+}
+
+// ListUserRepositories returns the repositories the token's owner can access,
+// most recently updated first, capped at maxRepos.
+func (s *GitHubService) ListUserRepositories(token string, maxRepos int) ([]models.GithubRepository, error) {
+	var all []models.GithubRepository
+	err := s.listPages("/user/repos?sort=updated&affiliation=owner,collaborator,organization_member", nil, token, "GitHub user not found", func(body []byte) (int, error) {
+		var page []models.GithubRepository
+		if err := json.Unmarshal(body, &page); err != nil {
+			return 0, err
+		}
+		all = append(all, page...)
+		if len(all) >= maxRepos {
+			// Report a short page to stop paginating
+			return 0, nil
+		}
+		return len(page), nil
+	})
+	if len(all) > maxRepos {
+		all = all[:maxRepos]
+	}
+	return all, err
+}
+
+// PullRequestSearch is the outcome of SearchPullRequests.
+type PullRequestSearch struct {
+	Items []models.GithubSearchIssue
+	// TotalCount is how many PRs GitHub matched, which may be more than the
+	// items fetched
+	TotalCount int
+	// Incomplete is set when GitHub gave up before finding every match
+	Incomplete bool
+}
+
+// SearchPullRequests runs a GitHub issue search restricted to pull requests and
+// returns up to maxResults of the most recently updated results, following
+// the result pages.
+func (s *GitHubService) SearchPullRequests(query string, token string, maxResults int) (*PullRequestSearch, error) {
+	params := url.Values{}
+	params.Set("q", "is:pr "+query)
+	params.Set("sort", "updated")
+	params.Set("order", "desc")
+
+	search := &PullRequestSearch{}
+	err := s.listPages("/search/issues?"+params.Encode(), nil, token, "GitHub search is not available", func(body []byte) (int, error) {
+		var page struct {
+			TotalCount        int                        `json:"total_count"`
+			IncompleteResults bool                       `json:"incomplete_results"`
+			Items             []models.GithubSearchIssue `json:"items"`
+		}
+		if err := json.Unmarshal(body, &page); err != nil {
+			return 0, err
+		}
+		search.Items = append(search.Items, page.Items...)
+		search.TotalCount = page.TotalCount
+		search.Incomplete = search.Incomplete || page.IncompleteResults
+		if len(search.Items) >= maxResults || len(search.Items) >= page.TotalCount {
+			// Report a short page to stop paginating
+			return 0, nil
+		}
+		return len(page.Items), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(search.Items) > maxResults {
+		search.Items = search.Items[:maxResults]
+	}
+	return search, nil
+}
+
+// GetAuthenticatedUser returns the login of the token's owner.
+func (s *GitHubService) GetAuthenticatedUser(token string) (string, error) {
+	var user struct {
+		Login string `json:"login"`
+	}
+	if err := s.getJSON("/user", token, "GitHub user not found", &user); err != nil {
+		return "", err
+	}
+	return user.Login, nil
+}
+
+// getJSON performs an authenticated GET against the GitHub REST API and decodes
+// a successful response into out. notFound is returned as the error message
+// when GitHub answers with 404.
+func (s *GitHubService) getJSON(path, token, notFound string, out interface{}) error {
+	return s.sendJSON("GET", path, token, notFound, nil, out)
+}
+
+// sendJSON performs an authenticated request with an optional JSON payload and
+// decodes a successful response into out.
+func (s *GitHubService) sendJSON(method, path, token, notFound string, in interface{}, out interface{}) error {
+	var payload io.Reader
+	if in != nil {
+		encoded, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("failed to encode request body: %w", err)
+		}
+		payload = bytes.NewReader(encoded)
 	}
 
-	// Clean token (remove "ghp_" prefix if present and clean whitespace)
+	resp, body, err := s.do(method, path, token, payload)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return statusError(resp.StatusCode, body, notFound)
+	}
+
+	if out != nil {
+		if err := json.Unmarshal(body, out); err != nil {
+			return fmt.Errorf("failed to decode GitHub response: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *GitHubService) do(method, path, token string, payload io.Reader) (*http.Response, []byte, error) {
+	// Clean token (remove surrounding whitespace)
 	token = strings.TrimSpace(token)
+	if token == "" {
+		return nil, nil, fmt.Errorf("GitHub token is required")
+	}
+
+	// Buffer the payload so a redirected write can be sent again
+	var data []byte
+	if payload != nil {
+		var err error
+		if data, err = io.ReadAll(payload); err != nil {
+			return nil, nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+	}
+
+	resp, body, err := s.send(method, s.baseURL+path, token, data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Writes to a moved repository come back as a redirect; replay them once
+	if isRedirect(resp.StatusCode) {
+		if location, err := resp.Location(); err == nil {
+			if resp, body, err = s.send(method, location.String(), token, data); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	s.detectRepositoryMove(path, resp, token)
+
+	return resp, body, nil
+}
 
-	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/pulls/%d", owner, repo, prNumber)
+func (s *GitHubService) send(method, rawURL, token string, data []byte) (*http.Response, []byte, error) {
+	var payload io.Reader
+	if data != nil {
+		payload = bytes.NewReader(data)
+	}
 
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequest(method, rawURL, payload)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	// Set headers for GitHub API v3
@@ -49,53 +447,301 @@ func (s *GitHubService) GetPullRequest(owner, repo string, prNumber int, token s
 	req.Header.Set("Accept", "application/vnd.github.v3+json")
 	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
 	req.Header.Set("User-Agent", "GitHub-Notes-App/1.0")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := s.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to make request to GitHub API: %w", err)
+		return nil, nil, fmt.Errorf("failed to make request to GitHub API: %w", err)
 	}
 	defer resp.Body.Close()
+	recordRateLimit(token, resp.Header)
 
 	// Read response body
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+		return nil, nil, fmt.Errorf("failed to read response body: %w", err)
 	}
 
-	// Handle different HTTP status codes
-	switch resp.StatusCode {
-	case http.StatusOK:
-		// Success - parse PR data
-		var pr models.GithubPullRequest
-		if err := json.Unmarshal(body, &pr); err != nil {
-			return nil, fmt.Errorf("failed to decode GitHub PR response: %w", err)
-		}
-		return &pr, nil
+	return resp, body, nil
+}
+
+// detectRepositoryMove reports a renamed or transferred repository. GitHub
+// answers requests for the old /repos/{owner}/{name} path with a redirect to
+// /repositories/{id}, so a request that ended up on a different path than it
+// started on means the repository moved.
+func (s *GitHubService) detectRepositoryMove(path string, resp *http.Response, token string) {
+	if s.onRepositoryMoved == nil || resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return
+	}
+
+	oldRepo := reposPathPattern.FindStringSubmatch(path)
+	if oldRepo == nil {
+		return
+	}
+
+	newRepo := repositoryPathPattern.FindStringSubmatch(resp.Request.URL.Path)
+	if newRepo == nil {
+		return
+	}
+
+	id, err := strconv.ParseInt(newRepo[1], 10, 64)
+	if err != nil {
+		return
+	}
+
+	s.onRepositoryMoved(oldRepo[1], oldRepo[2], id, token)
+}
+
+func isRedirect(statusCode int) bool {
+	switch statusCode {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// StatusError is returned when a code host answers with a non-successful
+// status code, so callers can react to specific statuses.
+type StatusError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *StatusError) Error() string {
+	return e.Message
+}
+
+// IsNotFound reports whether err is a 404 response from a code host.
+func IsNotFound(err error) bool {
+	statusErr, ok := err.(*StatusError)
+	return ok && statusErr.StatusCode == http.StatusNotFound
+}
 
+// statusError turns a non-successful GitHub response into a user facing error.
+func statusError(statusCode int, body []byte, notFound string) error {
+	return &StatusError{StatusCode: statusCode, Message: statusMessage(statusCode, body, notFound)}
+}
+
+func statusMessage(statusCode int, body []byte, notFound string) string {
+	switch statusCode {
 	case http.StatusNotFound:
-		return nil, fmt.Errorf("PR #%d not found in repository %s/%s. Please check if the repository exists and the PR number is correct", prNumber, owner, repo)
+		return notFound
 
 	case http.StatusUnauthorized:
-		return nil, fmt.Errorf("GitHub authentication failed. Please check your GitHub token and ensure it has the required permissions (public_repo, read:user)")
+		return "GitHub authentication failed. Please check your GitHub token and ensure it has the required permissions (public_repo, read:user)"
 
 	case http.StatusForbidden:
 		// Try to parse error message
 		var githubErr GitHubError
 		if json.Unmarshal(body, &githubErr) == nil && githubErr.Message != "" {
-			return nil, fmt.Errorf("GitHub API access forbidden: %s. Please check your token permissions", githubErr.Message)
+			return fmt.Sprintf("GitHub API access forbidden: %s. Please check your token permissions", githubErr.Message)
 		}
-		return nil, fmt.Errorf("GitHub API access forbidden. Your token may not have the required permissions or the repository may be private")
+		return "GitHub API access forbidden. Your token may not have the required permissions or the repository may be private"
 
 	case http.StatusTooManyRequests:
-		return nil, fmt.Errorf("GitHub API rate limit exceeded. Please try again later")
+		return "GitHub API rate limit exceeded. Please try again later"
 
 	default:
 		// Try to parse error message for other status codes
 		var githubErr GitHubError
 		if json.Unmarshal(body, &githubErr) == nil && githubErr.Message != "" {
-			return nil, fmt.Errorf("GitHub API error (status %d): %s", resp.StatusCode, githubErr.Message)
+			return fmt.Sprintf("GitHub API error (status %d): %s", statusCode, githubErr.Message)
 		}
-		return nil, fmt.Errorf("GitHub API returned unexpected status %d", resp.StatusCode)
+		return fmt.Sprintf("GitHub API returned unexpected status %d", statusCode)
+	}
+}
+
+// PullRequestFromGithub maps a GitHub REST pull request into the cached model.
+// Merged pull requests are stored with the "merged" state so that filters can
+// tell them apart from pull requests that were closed without merging.
+func PullRequestFromGithub(owner, repo string, pr *models.GithubPullRequest) models.PullRequest {
+	// Prefer the repository's current name in case it was renamed
+	if pr.Base.Repo.Owner.Login != "" && pr.Base.Repo.Name != "" {
+		owner, repo = pr.Base.Repo.Owner.Login, pr.Base.Repo.Name
+	}
+	owner, repo = models.NormalizeIdentifier(owner), models.NormalizeIdentifier(repo)
+
+	state := pr.State
+	if pr.Merged || pr.MergedAt != nil {
+		state = "merged"
+	}
+
+	return models.PullRequest{
+		Provider:  models.ProviderGitHub,
+		Host:      models.DefaultGitHubHost,
+		Number:    pr.Number,
+		RepoOwner: owner,
+		RepoName:  repo,
+		Title:     pr.Title,
+		Body:      pr.Body,
+		Author:    pr.User.Login,
+		State:     state,
+		Draft:     pr.Draft,
+		HeadSHA:   pr.Head.SHA,
+		MergedAt:  pr.MergedAt,
+		URL:       pr.HTMLURL,
+		Private:   pr.Base.Repo.Private,
+	}
+}
+
+// ApplyPullRequestUpdate copies freshly fetched pull request data onto a cached
+// row while keeping its identity (ID and timestamps) intact.
+func ApplyPullRequestUpdate(dst *models.PullRequest, src *models.PullRequest) {
+	dst.Title = src.Title
+	dst.Body = src.Body
+	dst.Author = src.Author
+	dst.State = src.State
+	dst.Draft = src.Draft
+	dst.HeadSHA = src.HeadSHA
+	dst.MergedAt = src.MergedAt
+	dst.URL = src.URL
+	dst.Private = src.Private
+	if src.HasChecks {
+		dst.ReviewDecision = src.ReviewDecision
+		dst.CIStatus = src.CIStatus
+	}
+	MarkFetched(dst)
+}
+
+// MarkFetched records a successful fetch of a cached PR.
+func MarkFetched(pr *models.PullRequest) {
+	now := time.Now()
+	pr.FetchStatus = models.FetchStatusOK
+	pr.SourceUnavailable = false
+	pr.LastError = ""
+	pr.LastCheckedAt = &now
+}
+
+// MarkFetchFailed records a failed fetch of a cached PR. Missing PRs and lost
+// access flag the PR as unavailable; transient failures such as rate limits or
+// timeouts only record the error and keep the previous status.
+func MarkFetchFailed(pr *models.PullRequest, err error) {
+	now := time.Now()
+	if status := fetchStatusForError(err); status != "" {
+		pr.FetchStatus = status
+		pr.SourceUnavailable = pr.Unavailable()
+	}
+	pr.LastError = err.Error()
+	pr.LastCheckedAt = &now
+}
+
+func fetchStatusForError(err error) string {
+	statusErr, ok := err.(*StatusError)
+	if !ok {
+		return ""
+	}
+
+	switch statusErr.StatusCode {
+	case http.StatusNotFound, http.StatusGone:
+		return models.FetchStatusNotFound
+	case http.StatusUnauthorized, http.StatusForbidden:
+		if IsRateLimited(err) {
+			return ""
+		}
+		return models.FetchStatusForbidden
+	}
+	return ""
+}
+
+// IssueFromGithub maps a GitHub REST issue into the cached model.
+func IssueFromGithub(owner, repo string, issue *models.GithubIssue) models.Issue {
+	// Prefer the repository's current name in case it was renamed
+	if currentOwner, currentRepo := RepoFromURL(issue.RepositoryURL); currentOwner != "" {
+		owner, repo = currentOwner, currentRepo
+	}
+	owner, repo = models.NormalizeIdentifier(owner), models.NormalizeIdentifier(repo)
+
+	labels := make([]string, 0, len(issue.Labels))
+	for _, label := range issue.Labels {
+		labels = append(labels, label.Name)
+	}
+
+	assignees := make([]string, 0, len(issue.Assignees))
+	for _, assignee := range issue.Assignees {
+		assignees = append(assignees, assignee.Login)
+	}
+
+	return models.Issue{
+		Host:        models.DefaultGitHubHost,
+		Number:      issue.Number,
+		RepoOwner:   owner,
+		RepoName:    repo,
+		Title:       issue.Title,
+		Body:        issue.Body,
+		Author:      issue.User.Login,
+		State:       issue.State,
+		StateReason: issue.StateReason,
+		Labels:      labels,
+		Assignees:   assignees,
+		URL:         issue.HTMLURL,
+	}
+}
+
+// CommitFromGithub maps a GitHub REST commit and its associated pull request
+// numbers into the cached model.
+func CommitFromGithub(owner, repo string, commit *models.GithubCommit, associatedPRs []int) models.Commit {
+	parents := make([]string, 0, len(commit.Parents))
+	for _, parent := range commit.Parents {
+		parents = append(parents, parent.SHA)
+	}
+
+	author := commit.Commit.Author.Name
+	if commit.Author != nil && commit.Author.Login != "" {
+		author = commit.Author.Login
+	}
+
+	if associatedPRs == nil {
+		associatedPRs = []int{}
+	}
+
+	// Prefer the repository's current name in case it was renamed; the
+	// HTML URL looks like https://github.com/{owner}/{name}/commit/{sha}
+	if parts := strings.Split(commit.HTMLURL, "/"); len(parts) >= 7 && parts[5] == "commit" {
+		owner, repo = parts[3], parts[4]
+	}
+	owner, repo = models.NormalizeIdentifier(owner), models.NormalizeIdentifier(repo)
+
+	return models.Commit{
+		SHA:           strings.ToLower(commit.SHA),
+		RepoOwner:     owner,
+		RepoName:      repo,
+		Message:       commit.Commit.Message,
+		Author:        author,
+		AuthorEmail:   commit.Commit.Author.Email,
+		AuthoredAt:    commit.Commit.Author.Date,
+		Parents:       parents,
+		Additions:     commit.Stats.Additions,
+		Deletions:     commit.Stats.Deletions,
+		ChangedFiles:  len(commit.Files),
+		AssociatedPRs: associatedPRs,
+		URL:           commit.HTMLURL,
+	}
+}
+
+// RepoFromURL extracts owner and name from a GitHub API repository URL such as
+// https://api.github.com/repos/owner/name.
+func RepoFromURL(repositoryURL string) (string, string) {
+	parts := strings.Split(strings.TrimRight(repositoryURL, "/"), "/")
+	if len(parts) < 2 {
+		return "", ""
+	}
+	return parts[len(parts)-2], parts[len(parts)-1]
+}
+
+// RepositoryFromGithub maps GitHub repository metadata into the registry model.
+func RepositoryFromGithub(repo *models.GithubRepository) models.Repository {
+	githubID := repo.ID
+	return models.Repository{
+		Provider:      models.ProviderGitHub,
+		Host:          models.DefaultGitHubHost,
+		Owner:         repo.Owner.Login,
+		Name:          repo.Name,
+		GithubID:      &githubID,
+		DefaultBranch: repo.DefaultBranch,
+		Private:       repo.Private,
+		Description:   repo.Description,
+		URL:           repo.HTMLURL,
 	}
 }
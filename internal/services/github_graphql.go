@@ -0,0 +1,263 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github-notes-backend/internal/models"
+)
+
+const (
+	defaultGraphQLURL       = "https://api.github.com/graphql"
+	defaultGraphQLBatchSize = 50
+)
+
+// PRRef identifies a pull request by repository and number.
+type PRRef struct {
+	Owner  string `json:"owner"`
+	Repo   string `json:"repo"`
+	Number int    `json:"number"`
+}
+
+func (r PRRef) String() string {
+	return fmt.Sprintf("%s/%s#%d", r.Owner, r.Repo, r.Number)
+}
+
+// GitHubGraphQLService fetches many pull requests per request through the
+// GitHub GraphQL API. Each pull request is requested under its own alias, so a
+// single query can return up to BatchSize pull requests.
+type GitHubGraphQLService struct {
+	endpoint  string
+	batchSize int
+	client    *http.Client
+}
+
+type graphQLRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables"`
+}
+
+type graphQLError struct {
+	Message string        `json:"message"`
+	Type    string        `json:"type"`
+	Path    []interface{} `json:"path"`
+}
+
+type graphQLResponse struct {
+	Data   map[string]*graphQLRepository `json:"data"`
+	Errors []graphQLError                `json:"errors"`
+}
+
+type graphQLRepository struct {
+	IsPrivate   bool                `json:"isPrivate"`
+	PullRequest *graphQLPullRequest `json:"pullRequest"`
+}
+
+type graphQLPullRequest struct {
+	Number     int        `json:"number"`
+	Title      string     `json:"title"`
+	Body       string     `json:"body"`
+	State      string     `json:"state"`
+	IsDraft    bool       `json:"isDraft"`
+	URL        string     `json:"url"`
+	HeadRefOid string     `json:"headRefOid"`
+	MergedAt   *time.Time `json:"mergedAt"`
+	Author     *struct {
+		Login string `json:"login"`
+	} `json:"author"`
+	ReviewDecision string `json:"reviewDecision"`
+	Commits        struct {
+		Nodes []struct {
+			Commit struct {
+				StatusCheckRollup *struct {
+					State string `json:"state"`
+				} `json:"statusCheckRollup"`
+			} `json:"commit"`
+		} `json:"nodes"`
+	} `json:"commits"`
+}
+
+const graphQLPullRequestFields = `number title body state isDraft url headRefOid mergedAt author { login } ` +
+	`reviewDecision commits(last: 1) { nodes { commit { statusCheckRollup { state } } } }`
+
+func NewGitHubGraphQLService(endpoint string, batchSize int) *GitHubGraphQLService {
+	if endpoint == "" {
+		endpoint = defaultGraphQLURL
+	}
+	if batchSize <= 0 {
+		batchSize = defaultGraphQLBatchSize
+	}
+	return &GitHubGraphQLService{
+		endpoint:  endpoint,
+		batchSize: batchSize,
+		client:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// GetPullRequests fetches the given pull requests in batches. The returned map
+// only contains pull requests that GitHub resolved; refs that were not found or
+// could not be fetched are reported in the errors map so that callers can fall
+// back to the REST API for them.
+func (s *GitHubGraphQLService) GetPullRequests(refs []PRRef, token string) (map[PRRef]*models.PullRequest, map[PRRef]error) {
+	results := make(map[PRRef]*models.PullRequest, len(refs))
+	failures := make(map[PRRef]error)
+
+	token = strings.TrimSpace(token)
+	if token == "" {
+		for _, ref := range refs {
+			failures[ref] = fmt.Errorf("GitHub token is required")
+		}
+		return results, failures
+	}
+
+	for start := 0; start < len(refs); start += s.batchSize {
+		end := start + s.batchSize
+		if end > len(refs) {
+			end = len(refs)
+		}
+		s.fetchBatch(refs[start:end], token, results, failures)
+	}
+
+	return results, failures
+}
+
+func (s *GitHubGraphQLService) fetchBatch(batch []PRRef, token string, results map[PRRef]*models.PullRequest, failures map[PRRef]error) {
+	var query strings.Builder
+	params := make([]string, 0, len(batch)*3)
+	variables := make(map[string]interface{}, len(batch)*3)
+
+	for i, ref := range batch {
+		params = append(params, fmt.Sprintf("$o%d: String!, $r%d: String!, $n%d: Int!", i, i, i))
+		variables[fmt.Sprintf("o%d", i)] = ref.Owner
+		variables[fmt.Sprintf("r%d", i)] = ref.Repo
+		variables[fmt.Sprintf("n%d", i)] = ref.Number
+		fmt.Fprintf(&query, "pr%d: repository(owner: $o%d, name: $r%d) { isPrivate pullRequest(number: $n%d) { %s } } ",
+			i, i, i, i, graphQLPullRequestFields)
+	}
+
+	payload := graphQLRequest{
+		Query:     fmt.Sprintf("query(%s) { %s}", strings.Join(params, ", "), query.String()),
+		Variables: variables,
+	}
+
+	resp, err := s.do(payload, token)
+	if err != nil {
+		for _, ref := range batch {
+			failures[ref] = err
+		}
+		return
+	}
+
+	// Errors are reported per alias, e.g. path ["pr3", "pullRequest"].
+	aliasErrors := make(map[string]string)
+	for _, gqlErr := range resp.Errors {
+		if len(gqlErr.Path) > 0 {
+			if alias, ok := gqlErr.Path[0].(string); ok {
+				aliasErrors[alias] = gqlErr.Message
+			}
+		}
+	}
+
+	for i, ref := range batch {
+		alias := fmt.Sprintf("pr%d", i)
+		repo := resp.Data[alias]
+		if repo == nil || repo.PullRequest == nil {
+			if msg, ok := aliasErrors[alias]; ok {
+				failures[ref] = fmt.Errorf("GitHub GraphQL error for %s: %s", ref, msg)
+			} else {
+				failures[ref] = fmt.Errorf("PR %s not found", ref)
+			}
+			continue
+		}
+		results[ref] = repo.PullRequest.toModel(ref, repo.IsPrivate)
+	}
+}
+
+func (s *GitHubGraphQLService) do(payload graphQLRequest, token string) (*graphQLResponse, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode GraphQL query: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "GitHub-Notes-App/1.0")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request to GitHub GraphQL API: %w", err)
+	}
+	defer resp.Body.Close()
+	recordRateLimit(token, resp.Header)
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized:
+		return nil, fmt.Errorf("GitHub authentication failed. Please check your GitHub token")
+	case http.StatusForbidden, http.StatusTooManyRequests:
+		return nil, fmt.Errorf("GitHub GraphQL API access forbidden or rate limited (status %d)", resp.StatusCode)
+	default:
+		return nil, fmt.Errorf("GitHub GraphQL API returned unexpected status %d", resp.StatusCode)
+	}
+
+	var result graphQLResponse
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return nil, fmt.Errorf("failed to decode GitHub GraphQL response: %w", err)
+	}
+
+	// A response without data means the whole query failed, e.g. because of
+	// a syntax or rate limit error rather than an individual missing PR.
+	if result.Data == nil && len(result.Errors) > 0 {
+		return nil, fmt.Errorf("GitHub GraphQL error: %s", result.Errors[0].Message)
+	}
+
+	return &result, nil
+}
+
+func (pr *graphQLPullRequest) toModel(ref PRRef, private bool) *models.PullRequest {
+	author := ""
+	if pr.Author != nil {
+		author = pr.Author.Login
+	}
+
+	ciStatus := ""
+	if nodes := pr.Commits.Nodes; len(nodes) > 0 && nodes[0].Commit.StatusCheckRollup != nil {
+		ciStatus = strings.ToLower(nodes[0].Commit.StatusCheckRollup.State)
+	}
+
+	return &models.PullRequest{
+		Provider:  models.ProviderGitHub,
+		Host:      models.DefaultGitHubHost,
+		Number:    pr.Number,
+		RepoOwner: ref.Owner,
+		RepoName:  ref.Repo,
+		Title:     pr.Title,
+		Body:      pr.Body,
+		Author:    author,
+		State:     strings.ToLower(pr.State),
+		Draft:     pr.IsDraft,
+		HeadSHA:   pr.HeadRefOid,
+		MergedAt:  pr.MergedAt,
+		URL:       pr.URL,
+		Private:   private,
+
+		ReviewDecision: strings.ToLower(pr.ReviewDecision),
+		CIStatus:       ciStatus,
+		HasChecks:      true,
+	}
+}
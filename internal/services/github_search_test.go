@@ -0,0 +1,81 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github-notes-backend/internal/models"
+)
+
+// newSearchTestServer answers /search/issues with full pages of results out
+// of total matches, flagging every page with incomplete.
+func newSearchTestServer(t *testing.T, total int, incomplete bool, requests *int) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/search/issues" {
+			http.NotFound(w, r)
+			return
+		}
+		*requests++
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+
+		items := []models.GithubSearchIssue{}
+		for n := (page-1)*perPage + 1; n <= total && n <= page*perPage; n++ {
+			items = append(items, models.GithubSearchIssue{Number: n})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"total_count":        total,
+			"incomplete_results": incomplete,
+			"items":              items,
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestSearchPullRequestsPaging(t *testing.T) {
+	tests := []struct {
+		name           string
+		total          int
+		incomplete     bool
+		maxResults     int
+		wantItems      int
+		wantRequests   int
+		wantIncomplete bool
+	}{
+		{"single short page", 42, false, 300, 42, 1, false},
+		{"exactly one full page", 100, false, 300, 100, 1, false},
+		{"follows pages", 250, false, 300, 250, 3, false},
+		{"stops at the cap", 1000, false, 300, 300, 3, false},
+		{"cap inside a page", 1000, false, 150, 150, 2, false},
+		{"search timed out", 42, true, 300, 42, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := 0
+			server := newSearchTestServer(t, tt.total, tt.incomplete, &requests)
+
+			result, err := NewGitHubEnterpriseService(server.URL).SearchPullRequests("author:mlee", "token", tt.maxResults)
+			if err != nil {
+				t.Fatalf("SearchPullRequests: %v", err)
+			}
+			if len(result.Items) != tt.wantItems {
+				t.Errorf("items = %d, want %d", len(result.Items), tt.wantItems)
+			}
+			if requests != tt.wantRequests {
+				t.Errorf("requests = %d, want %d", requests, tt.wantRequests)
+			}
+			if result.TotalCount != tt.total {
+				t.Errorf("total_count = %d, want %d", result.TotalCount, tt.total)
+			}
+			if result.Incomplete != tt.wantIncomplete {
+				t.Errorf("incomplete = %v, want %v", result.Incomplete, tt.wantIncomplete)
+			}
+		})
+	}
+}
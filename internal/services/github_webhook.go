@@ -0,0 +1,275 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github-notes-backend/internal/database"
+	"github-notes-backend/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// HandleGitHubWebhook logs a webhook delivery and applies it to the caches.
+// Deliveries that were already processed or ignored are not applied again and
+// are reported as duplicates; a failed delivery sent again is retried.
+func HandleGitHubWebhook(deliveryID, event string, payload []byte) (*models.WebhookDelivery, bool, error) {
+	delivery := models.WebhookDelivery{
+		Provider:   models.ProviderGitHub,
+		DeliveryID: deliveryID,
+		Event:      event,
+		Status:     models.WebhookFailed,
+		Payload:    string(payload),
+	}
+
+	// A delivery sent twice at once is only logged, and applied, by the
+	// request that inserts it
+	result := database.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "delivery_id"}},
+		DoNothing: true,
+	}).Create(&delivery)
+	if result.Error != nil {
+		return nil, false, result.Error
+	}
+	if result.RowsAffected > 0 {
+		return &delivery, false, ProcessWebhookDelivery(&delivery)
+	}
+
+	var logged models.WebhookDelivery
+	if err := database.DB.Where("delivery_id = ?", deliveryID).First(&logged).Error; err != nil {
+		return nil, false, err
+	}
+	if logged.Status != models.WebhookFailed {
+		return &logged, true, nil
+	}
+	return &logged, false, ProcessWebhookDelivery(&logged)
+}
+
+// ProcessWebhookDelivery applies a logged delivery to the caches and stores
+// the outcome on it. It is also used to replay deliveries.
+func ProcessWebhookDelivery(delivery *models.WebhookDelivery) error {
+	var payload models.GithubWebhookPayload
+	status, summary := models.WebhookFailed, ""
+	err := json.Unmarshal([]byte(delivery.Payload), &payload)
+	if err == nil {
+		status, summary, err = applyGitHubEvent(delivery.Event, &payload)
+	} else {
+		err = fmt.Errorf("invalid payload: %w", err)
+	}
+
+	now := time.Now()
+	delivery.Attempts++
+	delivery.ProcessedAt = &now
+	delivery.Action = payload.Action
+	if payload.Repository != nil {
+		delivery.Repository = payload.Repository.FullName
+	}
+	delivery.Status = status
+	delivery.Summary = summary
+	delivery.Error = ""
+	if err != nil {
+		delivery.Status = models.WebhookFailed
+		delivery.Error = err.Error()
+	}
+
+	if saveErr := database.DB.Save(delivery).Error; saveErr != nil && err == nil {
+		err = saveErr
+	}
+	return err
+}
+
+// applyGitHubEvent updates the caches from one event and returns the delivery
+// status with a short summary of what happened.
+func applyGitHubEvent(event string, payload *models.GithubWebhookPayload) (string, string, error) {
+	switch event {
+	case "pull_request":
+		if payload.Repository == nil || payload.PullRequest == nil {
+			return "", "", fmt.Errorf("pull_request payload without repository or pull_request")
+		}
+		_, status, summary, err := upsertWebhookPullRequest(payload.Repository, payload.PullRequest)
+		return status, summary, err
+	case "pull_request_review":
+		if payload.Repository == nil || payload.PullRequest == nil || payload.Review == nil {
+			return "", "", fmt.Errorf("pull_request_review payload without repository, pull_request or review")
+		}
+		return applyWebhookReview(payload)
+	case "issue_comment":
+		if payload.Repository == nil || payload.Issue == nil || payload.Comment == nil {
+			return "", "", fmt.Errorf("issue_comment payload without repository, issue or comment")
+		}
+		if payload.Issue.PullRequest != nil {
+			return applyWebhookPRComment(payload)
+		}
+		return updateWebhookIssue(payload.Repository, payload.Issue)
+	default:
+		return models.WebhookIgnored, fmt.Sprintf("event %q is not handled", event), nil
+	}
+}
+
+// upsertWebhookPullRequest updates the cached PR from the payload. PRs that
+// are not cached yet are only stored when their repository is in the registry,
+// so that webhooks for busy repositories do not fill the cache with PRs no
+// note links to. It returns the cached PR, or nil when the payload was
+// ignored.
+func upsertWebhookPullRequest(repoData *models.GithubRepository, prData *models.GithubPullRequest) (*models.PullRequest, string, string, error) {
+	repo, err := followWebhookRepository(repoData)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	data := PullRequestFromGithub(repoData.Owner.Login, repoData.Name, prData)
+	label := fmt.Sprintf("%s/%s#%d", data.RepoOwner, data.RepoName, data.Number)
+
+	var pr models.PullRequest
+	err = database.DB.Where("provider = ? AND host = ? AND repo_owner = ? AND repo_name = ? AND number = ?",
+		models.ProviderGitHub, models.DefaultGitHubHost, data.RepoOwner, data.RepoName, data.Number).First(&pr).Error
+	if err == nil {
+		changes, err := applyRefresh(&pr, &data, models.EventSourceWebhook)
+		if err != nil {
+			return nil, "", "", err
+		}
+		return &pr, models.WebhookProcessed, fmt.Sprintf("updated %s (%d fields changed)", label, len(changes)), nil
+	}
+	if err != gorm.ErrRecordNotFound {
+		return nil, "", "", err
+	}
+
+	if repo == nil {
+		return nil, models.WebhookIgnored, label + " is not tracked", nil
+	}
+
+	data.RepositoryID = &repo.ID
+	MarkFetched(&data)
+	if err := UpsertPullRequest(&data); err != nil {
+		return nil, "", "", err
+	}
+	return &data, models.WebhookProcessed, "cached " + label, nil
+}
+
+// applyWebhookReview updates the cached PR from a pull_request_review payload
+// and records submitted and dismissed reviews on its timeline as a "review"
+// event whose new value is "<reviewer>: <state>".
+func applyWebhookReview(payload *models.GithubWebhookPayload) (string, string, error) {
+	pr, status, summary, err := upsertWebhookPullRequest(payload.Repository, payload.PullRequest)
+	if err != nil || pr == nil {
+		return status, summary, err
+	}
+
+	review := payload.Review
+	if payload.Action != "submitted" && payload.Action != "dismissed" {
+		return status, summary, nil
+	}
+
+	state := strings.ToLower(review.State)
+	if err := RecordPullRequestEvents(pr.ID, []models.FieldChange{
+		{Field: "review", After: review.User.Login + ": " + state},
+	}, models.EventSourceWebhook); err != nil {
+		return "", "", err
+	}
+	return status, fmt.Sprintf("%s, review %d by %s %s", summary, review.ID, review.User.Login, state), nil
+}
+
+// applyWebhookPRComment stores, updates or removes a conversation comment on
+// a cached PR.
+func applyWebhookPRComment(payload *models.GithubWebhookPayload) (string, string, error) {
+	if _, err := followWebhookRepository(payload.Repository); err != nil {
+		return "", "", err
+	}
+
+	repoData, issue, comment := payload.Repository, payload.Issue, payload.Comment
+	label := fmt.Sprintf("%s#%d", repoData.FullName, issue.Number)
+
+	var pr models.PullRequest
+	err := database.DB.Where("provider = ? AND host = ? AND repo_owner = ? AND repo_name = ? AND number = ?",
+		models.ProviderGitHub, models.DefaultGitHubHost, models.NormalizeIdentifier(repoData.Owner.Login),
+		models.NormalizeIdentifier(repoData.Name), issue.Number).First(&pr).Error
+	if err == gorm.ErrRecordNotFound {
+		return models.WebhookIgnored, label + " is not tracked", nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	if payload.Action == "deleted" {
+		if err := database.DB.Where("kind = ? AND github_id = ?", models.PRCommentIssue, comment.ID).
+			Delete(&models.PRComment{}).Error; err != nil {
+			return "", "", err
+		}
+		return models.WebhookProcessed, fmt.Sprintf("deleted comment %d on %s", comment.ID, label), nil
+	}
+
+	if err := UpsertPRComment(&models.PRComment{
+		PullRequestID:   pr.ID,
+		GithubID:        comment.ID,
+		Kind:            models.PRCommentIssue,
+		Author:          comment.User.Login,
+		Body:            comment.Body,
+		URL:             comment.HTMLURL,
+		GithubCreatedAt: comment.CreatedAt,
+		GithubUpdatedAt: comment.UpdatedAt,
+	}); err != nil {
+		return "", "", err
+	}
+	return models.WebhookProcessed, fmt.Sprintf("stored comment %d on %s", comment.ID, label), nil
+}
+
+// updateWebhookIssue refreshes a cached issue from an issue_comment payload.
+// Comments on plain issues are not cached themselves.
+func updateWebhookIssue(repoData *models.GithubRepository, issueData *models.GithubIssue) (string, string, error) {
+	if _, err := followWebhookRepository(repoData); err != nil {
+		return "", "", err
+	}
+
+	fresh := IssueFromGithub(repoData.Owner.Login, repoData.Name, issueData)
+	fresh.Private = repoData.Private
+	label := fmt.Sprintf("%s/%s#%d", fresh.RepoOwner, fresh.RepoName, fresh.Number)
+
+	var issue models.Issue
+	err := database.DB.Where("host = ? AND number = ? AND repo_owner = ? AND repo_name = ?",
+		models.DefaultGitHubHost, fresh.Number, fresh.RepoOwner, fresh.RepoName).First(&issue).Error
+	if err == gorm.ErrRecordNotFound {
+		return models.WebhookIgnored, label + " is not tracked", nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	fresh.ID = issue.ID
+	fresh.CreatedAt = issue.CreatedAt
+	if err := database.DB.Save(&fresh).Error; err != nil {
+		return "", "", err
+	}
+	return models.WebhookProcessed, "updated issue " + label, nil
+}
+
+// followWebhookRepository returns the registry row of the payload repository,
+// or nil when it is not registered. Since payloads carry the stable GitHub id,
+// a registered repository stored under another name was renamed or
+// transferred and is moved to its current name.
+func followWebhookRepository(repoData *models.GithubRepository) (*models.Repository, error) {
+	var repo models.Repository
+	err := database.DB.Where("github_id = ? AND host = ?", repoData.ID, models.DefaultGitHubHost).First(&repo).Error
+	if err == gorm.ErrRecordNotFound {
+		err = database.DB.Where("provider = ? AND host = ? AND LOWER(owner) = LOWER(?) AND LOWER(name) = LOWER(?)",
+			models.ProviderGitHub, models.DefaultGitHubHost, repoData.Owner.Login, repoData.Name).First(&repo).Error
+	}
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.EqualFold(repo.Owner, repoData.Owner.Login) || !strings.EqualFold(repo.Name, repoData.Name) {
+		if err := RenameRepository(repo.Owner, repo.Name, repoData); err != nil {
+			return nil, err
+		}
+		if err := database.DB.First(&repo, repo.ID).Error; err != nil {
+			return nil, err
+		}
+	}
+	return &repo, nil
+}
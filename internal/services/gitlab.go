@@ -0,0 +1,134 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github-notes-backend/internal/models"
+)
+
+// GitLabService talks to the REST API (v4) of a GitLab instance.
+type GitLabService struct {
+	restClient
+}
+
+type gitLabMergeRequest struct {
+	IID            int        `json:"iid"`
+	Title          string     `json:"title"`
+	Description    string     `json:"description"`
+	State          string     `json:"state"`
+	Draft          bool       `json:"draft"`
+	WorkInProgress bool       `json:"work_in_progress"`
+	SHA            string     `json:"sha"`
+	MergedAt       *time.Time `json:"merged_at"`
+	WebURL         string     `json:"web_url"`
+	Author         struct {
+		Username string `json:"username"`
+	} `json:"author"`
+}
+
+func NewGitLabService(baseURL string) *GitLabService {
+	return &GitLabService{
+		restClient: newRESTClient("GitLab", baseURL, func(req *http.Request, token string) {
+			req.Header.Set("PRIVATE-TOKEN", token)
+		}),
+	}
+}
+
+func (s *GitLabService) Name() string {
+	return models.ProviderGitLab
+}
+
+// FetchChangeRequest loads a merge request by project path and iid.
+func (s *GitLabService) FetchChangeRequest(ref ChangeRequestRef, token string) (*models.PullRequest, error) {
+	if ref.Owner == "" || ref.Repo == "" {
+		return nil, fmt.Errorf("project namespace and name are required")
+	}
+	if ref.Number <= 0 {
+		return nil, fmt.Errorf("merge request iid must be greater than 0")
+	}
+
+	project := url.PathEscape(ref.Owner + "/" + ref.Repo)
+	path := fmt.Sprintf("/api/v4/projects/%s/merge_requests/%d", project, ref.Number)
+	notFound := fmt.Sprintf("Merge request !%d not found in project %s/%s. Please check if the project exists and the iid is correct", ref.Number, ref.Owner, ref.Repo)
+
+	var mr gitLabMergeRequest
+	if err := s.getJSON(path, token, notFound, &mr); err != nil {
+		return nil, err
+	}
+
+	// The MR API does not report project visibility, and internal projects
+	// need an account anyway, so merge requests are always treated as private
+	host, _ := HostFromURL(s.baseURL)
+	return &models.PullRequest{
+		Provider:  models.ProviderGitLab,
+		Host:      host,
+		Number:    mr.IID,
+		RepoOwner: ref.Owner,
+		RepoName:  ref.Repo,
+		Title:     mr.Title,
+		Body:      mr.Description,
+		Author:    mr.Author.Username,
+		State:     gitLabState(mr.State),
+		Draft:     mr.Draft || mr.WorkInProgress,
+		HeadSHA:   mr.SHA,
+		MergedAt:  mr.MergedAt,
+		URL:       mr.WebURL,
+		Private:   true,
+	}, nil
+}
+
+// gitLabState maps GitLab MR states onto the normalized PR states.
+func gitLabState(state string) string {
+	switch state {
+	case "opened":
+		return "open"
+	case "merged":
+		return "merged"
+	default:
+		// closed and locked merge requests can no longer be merged
+		return "closed"
+	}
+}
+
+var gitLabMergeRequestPathPattern = regexp.MustCompile(`^/(.+)/([^/]+)/-/merge_requests/(\d+)/?`)
+
+// ParseURL parses merge request URLs such as
+// https://gitlab.example.com/group/subgroup/project/-/merge_requests/42. On
+// an instance served under a subpath, the base URL's path is not part of the
+// namespace.
+func (s *GitLabService) ParseURL(rawURL string) (*ChangeRequestRef, error) {
+	parsed, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || parsed.Host == "" {
+		return nil, fmt.Errorf("not a GitLab merge request URL: %s", rawURL)
+	}
+
+	matches := gitLabMergeRequestPathPattern.FindStringSubmatch(s.sitePath(parsed.Path))
+	if matches == nil {
+		return nil, fmt.Errorf("not a GitLab merge request URL: %s", rawURL)
+	}
+
+	number, _ := strconv.Atoi(matches[3])
+	return &ChangeRequestRef{
+		Provider: models.ProviderGitLab,
+		Host:     strings.ToLower(parsed.Host),
+		Owner:    matches[1],
+		Repo:     matches[2],
+		Number:   number,
+	}, nil
+}
+
+func (s *GitLabService) ValidateToken(token string) (string, error) {
+	var user struct {
+		Username string `json:"username"`
+	}
+	if err := s.getJSON("/api/v4/user", token, "GitLab user not found", &user); err != nil {
+		return "", err
+	}
+	return user.Username, nil
+}
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github-notes-backend/internal/models"
+)
+
+const gitLabTestToken = "glpat-test"
+
+// newGitLabTestServer serves the recorded merge request and user responses
+// of a GitLab instance living under /code.
+func newGitLabTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	mergeRequest, err := os.ReadFile("testdata/gitlab_merge_request.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("PRIVATE-TOKEN") != gitLabTestToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"message": "401 Unauthorized"}`))
+			return
+		}
+		switch r.URL.EscapedPath() {
+		case "/code/api/v4/projects/platform%2Fci%2Frunner-images/merge_requests/42":
+			w.Write(mergeRequest)
+		case "/code/api/v4/user":
+			w.Write([]byte(`{"id": 19, "username": "jdoe"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message": "404 Not found"}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestGitLabFetchChangeRequest(t *testing.T) {
+	server := newGitLabTestServer(t)
+	gitlab := NewGitLabService(server.URL + "/code")
+
+	pr, err := gitlab.FetchChangeRequest(ChangeRequestRef{Owner: "platform/ci", Repo: "runner-images", Number: 42}, gitLabTestToken)
+	if err != nil {
+		t.Fatalf("FetchChangeRequest: %v", err)
+	}
+
+	host, _ := HostFromURL(server.URL)
+	if pr.Provider != models.ProviderGitLab || pr.Host != host {
+		t.Errorf("provider/host = %s/%s, want %s/%s", pr.Provider, pr.Host, models.ProviderGitLab, host)
+	}
+	if pr.Number != 42 || pr.RepoOwner != "platform/ci" || pr.RepoName != "runner-images" {
+		t.Errorf("ref = %s/%s!%d, want platform/ci/runner-images!42", pr.RepoOwner, pr.RepoName, pr.Number)
+	}
+	if pr.State != "open" || !pr.Draft || pr.MergedAt != nil {
+		t.Errorf("state = %q, draft = %v, merged_at = %v; want an open draft", pr.State, pr.Draft, pr.MergedAt)
+	}
+	if pr.Author != "jdoe" || pr.HeadSHA != "5e1b9d3f7a2c6e0b4d8f2a6c0e4b8d2f6a0c4e8b" {
+		t.Errorf("author/head = %s/%s", pr.Author, pr.HeadSHA)
+	}
+	if !pr.Private {
+		t.Error("merge requests should always be private")
+	}
+}
+
+func TestGitLabFetchChangeRequestErrors(t *testing.T) {
+	server := newGitLabTestServer(t)
+	gitlab := NewGitLabService(server.URL + "/code")
+
+	tests := []struct {
+		name   string
+		ref    ChangeRequestRef
+		token  string
+		status int
+		fetch  string
+	}{
+		{"missing MR", ChangeRequestRef{Owner: "platform/ci", Repo: "runner-images", Number: 43}, gitLabTestToken, http.StatusNotFound, models.FetchStatusNotFound},
+		{"bad token", ChangeRequestRef{Owner: "platform/ci", Repo: "runner-images", Number: 42}, "revoked", http.StatusUnauthorized, models.FetchStatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := gitlab.FetchChangeRequest(tt.ref, tt.token)
+			statusErr, ok := err.(*StatusError)
+			if !ok {
+				t.Fatalf("err = %v, want a *StatusError", err)
+			}
+			if statusErr.StatusCode != tt.status {
+				t.Errorf("status = %d, want %d", statusErr.StatusCode, tt.status)
+			}
+			if got := fetchStatusForError(err); got != tt.fetch {
+				t.Errorf("fetch status = %q, want %q", got, tt.fetch)
+			}
+		})
+	}
+}
+
+func TestGitLabParseURL(t *testing.T) {
+	tests := []struct {
+		baseURL string
+		url     string
+		want    *ChangeRequestRef
+	}{
+		{"", "https://gitlab.com/group/project/-/merge_requests/7", &ChangeRequestRef{Provider: models.ProviderGitLab, Host: "gitlab.com", Owner: "group", Repo: "project", Number: 7}},
+		{"", "https://GitLab.Example.com/group/sub/project/-/merge_requests/42/diffs", &ChangeRequestRef{Provider: models.ProviderGitLab, Host: "gitlab.example.com", Owner: "group/sub", Repo: "project", Number: 42}},
+		{"https://gitlab.example.com/code", "https://gitlab.example.com/code/platform/ci/runner-images/-/merge_requests/42", &ChangeRequestRef{Provider: models.ProviderGitLab, Host: "gitlab.example.com", Owner: "platform/ci", Repo: "runner-images", Number: 42}},
+		{"https://gitlab.example.com/code", "https://gitlab.example.com/codebase/project/-/merge_requests/1", &ChangeRequestRef{Provider: models.ProviderGitLab, Host: "gitlab.example.com", Owner: "codebase", Repo: "project", Number: 1}},
+		{"", "https://gitlab.com/group/project/-/issues/7", nil},
+		{"", "https://github.com/owner/repo/pull/1", nil},
+	}
+	for _, tt := range tests {
+		got, err := NewGitLabService(tt.baseURL).ParseURL(tt.url)
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("ParseURL(%q) = %+v, want an error", tt.url, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseURL(%q): %v", tt.url, err)
+			continue
+		}
+		if *got != *tt.want {
+			t.Errorf("ParseURL(%q) = %+v, want %+v", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestGitLabValidateToken(t *testing.T) {
+	server := newGitLabTestServer(t)
+	gitlab := NewGitLabService(server.URL + "/code")
+
+	username, err := gitlab.ValidateToken(gitLabTestToken)
+	if err != nil || username != "jdoe" {
+		t.Fatalf("ValidateToken = %q, %v; want jdoe", username, err)
+	}
+
+	_, err = gitlab.ValidateToken("revoked")
+	if statusErr, ok := err.(*StatusError); !ok || statusErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("err = %v, want a 401 *StatusError", err)
+	}
+
+	// A wrong base URL answers the user endpoint with 404
+	_, err = NewGitLabService(server.URL).ValidateToken(gitLabTestToken)
+	if !IsNotFound(err) {
+		t.Fatalf("err = %v, want a 404", err)
+	}
+}
@@ -0,0 +1,38 @@
+package services
+
+import (
+	"github-notes-backend/internal/database"
+	"github-notes-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm/clause"
+)
+
+// issueKey is the unique key of the issue cache.
+var issueKey = []clause.Column{
+	{Name: "host"}, {Name: "repo_owner"}, {Name: "repo_name"}, {Name: "number"},
+}
+
+// UpsertIssue stores issue, or refreshes the cached row with the same host,
+// repository and number if another request got there first. On return
+// issue.ID is the id of the stored row.
+func UpsertIssue(issue *models.Issue) error {
+	if issue.ID == uuid.Nil {
+		issue.ID = uuid.New()
+	}
+
+	updates := clause.AssignmentColumns([]string{"title", "body", "author", "state", "state_reason",
+		"labels", "assignees", "url", "private", "updated_at"})
+	if err := database.DB.Clauses(clause.OnConflict{Columns: issueKey, DoUpdates: updates}).Create(issue).Error; err != nil {
+		return err
+	}
+
+	// The insert may have turned into an update of an existing row
+	var stored models.Issue
+	if err := database.DB.Where("host = ? AND repo_owner = ? AND repo_name = ? AND number = ?",
+		issue.Host, issue.RepoOwner, issue.RepoName, issue.Number).First(&stored).Error; err != nil {
+		return err
+	}
+	*issue = stored
+	return nil
+}
@@ -0,0 +1,16 @@
+package services
+
+import (
+	"github-notes-backend/internal/database"
+	"github-notes-backend/internal/models"
+
+	"gorm.io/gorm/clause"
+)
+
+// UpsertPRComment inserts a comment or refreshes the cached copy of it.
+func UpsertPRComment(comment *models.PRComment) error {
+	return database.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "kind"}, {Name: "github_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"author", "body", "path", "line", "in_reply_to_id", "url", "github_updated_at", "updated_at"}),
+	}).Create(comment).Error
+}
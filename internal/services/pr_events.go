@@ -0,0 +1,57 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github-notes-backend/internal/database"
+	"github-notes-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// RecordPullRequestEvents stores the observed changes to a cached PR on its
+// timeline.
+func RecordPullRequestEvents(prID uuid.UUID, changes []models.FieldChange, source string) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	events := make([]models.PullRequestEvent, len(changes))
+	for i, change := range changes {
+		events[i] = models.PullRequestEvent{
+			ID:            uuid.New(),
+			PullRequestID: prID,
+			Field:         change.Field,
+			OldValue:      eventValue(change.Before),
+			NewValue:      eventValue(change.After),
+			Source:        source,
+			ObservedAt:    now,
+		}
+	}
+	return database.DB.Create(&events).Error
+}
+
+// eventValue renders a changed field value for the timeline. Unset values
+// are stored as an empty string.
+func eventValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case *time.Time:
+		if v == nil {
+			return ""
+		}
+		return v.UTC().Format(time.RFC3339)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,258 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	"github-notes-backend/internal/config"
+	"github-notes-backend/internal/database"
+	"github-notes-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// PRRefresher keeps cached pull requests fresh. How long a cached PR is
+// trusted depends on its state: open PRs change often, merged and closed ones
+// rarely.
+type PRRefresher struct {
+	github  *GitHubService
+	graphql *GitHubGraphQLService
+	ttls    map[string]time.Duration
+	openTTL time.Duration
+
+	// Manual refreshes are limited to one per user per cooldown
+	cooldown         time.Duration
+	cooldownMu       sync.Mutex
+	lastManualByUser map[uuid.UUID]time.Time
+}
+
+type RefreshFailure struct {
+	PullRequest string `json:"pull_request"`
+	FetchStatus string `json:"fetch_status"`
+	Error       string `json:"error"`
+}
+
+type RefreshResult struct {
+	Refreshed int              `json:"refreshed"`
+	Failed    []RefreshFailure `json:"failed"`
+}
+
+func NewPRRefresher(cfg *config.Config) *PRRefresher {
+	return &PRRefresher{
+		github:  NewGitHubService(),
+		graphql: NewGitHubGraphQLService(cfg.GithubGraphQLURL, cfg.GithubGraphQLBatchSize),
+		ttls: map[string]time.Duration{
+			"closed": cfg.PRCacheTTLClosed,
+			"merged": cfg.PRCacheTTLMerged,
+		},
+		openTTL:          cfg.PRCacheTTLOpen,
+		cooldown:         cfg.PRManualRefreshCooldown,
+		lastManualByUser: make(map[uuid.UUID]time.Time),
+	}
+}
+
+// AllowManualRefresh claims the user's manual refresh slot. When the user
+// refreshed within the cooldown it returns false and how long to wait.
+func (r *PRRefresher) AllowManualRefresh(userID uuid.UUID) (time.Duration, bool) {
+	r.cooldownMu.Lock()
+	defer r.cooldownMu.Unlock()
+
+	now := time.Now()
+	if last, ok := r.lastManualByUser[userID]; ok {
+		if wait := r.cooldown - now.Sub(last); wait > 0 {
+			return wait, false
+		}
+	}
+	r.lastManualByUser[userID] = now
+	return 0, true
+}
+
+// TTL returns how long a PR in state stays fresh. Unknown states are treated
+// like open PRs.
+func (r *PRRefresher) TTL(state string) time.Duration {
+	if ttl, ok := r.ttls[state]; ok {
+		return ttl
+	}
+	return r.openTTL
+}
+
+// IsStale reports whether the PR's TTL has expired since it was last fetched.
+func (r *PRRefresher) IsStale(pr *models.PullRequest) bool {
+	checkedAt := pr.UpdatedAt
+	if pr.LastCheckedAt != nil {
+		checkedAt = *pr.LastCheckedAt
+	}
+	return time.Since(checkedAt) > r.TTL(pr.State)
+}
+
+// StaleScope limits a pull_requests query to PRs whose TTL has expired.
+func (r *PRRefresher) StaleScope(db *gorm.DB) *gorm.DB {
+	now := time.Now()
+	states := make([]string, 0, len(r.ttls))
+	for state := range r.ttls {
+		states = append(states, state)
+	}
+
+	query := database.DB.Where("state NOT IN ? AND COALESCE(last_checked_at, updated_at) < ?", states, now.Add(-r.openTTL))
+	for state, ttl := range r.ttls {
+		query = query.Or("state = ? AND COALESCE(last_checked_at, updated_at) < ?", state, now.Add(-ttl))
+	}
+	return db.Where(query)
+}
+
+// Refresh re-fetches prs with the user's credentials and saves them, updating
+// them in place. Changes are recorded on each PR's timeline under source.
+// github.com PRs are fetched in batches through GraphQL; anything GraphQL
+// could not resolve, and PRs from other hosts, are fetched one by one. Once
+// GitHub reports the rate limit as exceeded the remaining github.com PRs are
+// skipped.
+func (r *PRRefresher) Refresh(prs []*models.PullRequest, user *models.User, source string) RefreshResult {
+	var githubRefs []PRRef
+	for _, pr := range prs {
+		if models.IsGitHubDotCom(pr.Provider, pr.Host) {
+			githubRefs = append(githubRefs, PRRefFor(pr))
+		}
+	}
+
+	var fetched map[PRRef]*models.PullRequest
+	if len(githubRefs) > 0 && user.GithubToken != "" {
+		fetched, _ = r.graphql.GetPullRequests(githubRefs, user.GithubToken)
+	}
+
+	result := RefreshResult{Failed: []RefreshFailure{}}
+	var rateLimitErr error
+	for _, pr := range prs {
+		ref := PRRefFor(pr)
+		dotCom := models.IsGitHubDotCom(pr.Provider, pr.Host)
+
+		data, ok := fetched[ref]
+		if !ok || !dotCom {
+			// Fall back to the provider's REST API for anything GraphQL did not return
+			var err error
+			if dotCom && rateLimitErr != nil {
+				err = rateLimitErr
+			} else {
+				data, err = r.fetchChangeRequest(pr, user)
+			}
+			if err != nil {
+				if IsRateLimited(err) && dotCom {
+					rateLimitErr = err
+				}
+				RecordFetchFailure(pr, err, source)
+				result.Failed = append(result.Failed, RefreshFailure{PullRequest: ref.String(), FetchStatus: pr.FetchStatus, Error: err.Error()})
+				continue
+			}
+		}
+
+		if _, err := applyRefresh(pr, data, source); err != nil {
+			result.Failed = append(result.Failed, RefreshFailure{PullRequest: ref.String(), FetchStatus: pr.FetchStatus, Error: "Failed to save PR information"})
+			continue
+		}
+		result.Refreshed++
+	}
+
+	return result
+}
+
+// RefreshOne re-fetches a single PR through its provider's REST API with the
+// user's credentials, saves it and returns the fields that changed.
+func (r *PRRefresher) RefreshOne(pr *models.PullRequest, user *models.User, source string) ([]models.FieldChange, error) {
+	data, err := r.fetchChangeRequest(pr, user)
+	if err != nil {
+		RecordFetchFailure(pr, err, source)
+		return nil, err
+	}
+	return applyRefresh(pr, data, source)
+}
+
+// applyRefresh copies freshly fetched data onto a cached PR, saves it and
+// records what changed on its timeline.
+func applyRefresh(pr *models.PullRequest, data *models.PullRequest, source string) ([]models.FieldChange, error) {
+	before := *pr
+	ApplyPullRequestUpdate(pr, data)
+	if err := database.DB.Save(pr).Error; err != nil {
+		return nil, err
+	}
+
+	changes := DiffPullRequests(&before, pr)
+	RecordPullRequestEvents(pr.ID, changes, source)
+	return changes, nil
+}
+
+// RecordFetchFailure stores a failed fetch on the PR. Missing credentials and
+// rate limits say nothing about the PR itself and are not recorded.
+func RecordFetchFailure(pr *models.PullRequest, err error, source string) {
+	if _, ok := err.(*CredentialError); ok || IsRateLimited(err) {
+		return
+	}
+
+	before := pr.FetchStatus
+	MarkFetchFailed(pr, err)
+	if err := SaveFetchStatus(pr); err != nil {
+		return
+	}
+	if pr.FetchStatus != before {
+		RecordPullRequestEvents(pr.ID, []models.FieldChange{{Field: "fetch_status", Before: before, After: pr.FetchStatus}}, source)
+	}
+}
+
+// DiffPullRequests lists the tracked fields that differ between two versions
+// of a cached PR.
+func DiffPullRequests(before, after *models.PullRequest) []models.FieldChange {
+	changes := []models.FieldChange{}
+	add := func(field string, from, to interface{}, changed bool) {
+		if changed {
+			changes = append(changes, models.FieldChange{Field: field, Before: from, After: to})
+		}
+	}
+
+	add("title", before.Title, after.Title, before.Title != after.Title)
+	add("body", before.Body, after.Body, before.Body != after.Body)
+	add("author", before.Author, after.Author, before.Author != after.Author)
+	add("state", before.State, after.State, before.State != after.State)
+	add("draft", before.Draft, after.Draft, before.Draft != after.Draft)
+	add("head_sha", before.HeadSHA, after.HeadSHA, before.HeadSHA != after.HeadSHA)
+	add("merged_at", before.MergedAt, after.MergedAt, !sameTime(before.MergedAt, after.MergedAt))
+	add("url", before.URL, after.URL, before.URL != after.URL)
+	add("private", before.Private, after.Private, before.Private != after.Private)
+	add("review_decision", before.ReviewDecision, after.ReviewDecision, before.ReviewDecision != after.ReviewDecision)
+	add("ci_status", before.CIStatus, after.CIStatus, before.CIStatus != after.CIStatus)
+	add("fetch_status", before.FetchStatus, after.FetchStatus, before.FetchStatus != after.FetchStatus)
+
+	return changes
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// SaveFetchStatus stores the outcome of the last fetch of a cached PR.
+func SaveFetchStatus(pr *models.PullRequest) error {
+	return database.DB.Model(pr).Select("FetchStatus", "LastError", "LastCheckedAt").Updates(pr).Error
+}
+
+// fetchChangeRequest re-fetches a cached PR from its code host with the
+// user's credential for that host.
+func (r *PRRefresher) fetchChangeRequest(pr *models.PullRequest, user *models.User) (*models.PullRequest, error) {
+	client, err := ProviderForUser(r.github, user, pr.Provider, pr.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.Provider.FetchChangeRequest(ChangeRequestRef{
+		Provider: pr.Provider,
+		Host:     pr.Host,
+		Owner:    pr.RepoOwner,
+		Repo:     pr.RepoName,
+		Number:   pr.Number,
+	}, client.Token)
+}
+
+// PRRefFor returns the GraphQL reference of a cached PR.
+func PRRefFor(pr *models.PullRequest) PRRef {
+	return PRRef{Owner: pr.RepoOwner, Repo: pr.RepoName, Number: pr.Number}
+}
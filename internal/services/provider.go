@@ -0,0 +1,159 @@
+package services
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github-notes-backend/internal/models"
+)
+
+// ChangeRequestRef identifies a pull request or merge request on a code host.
+// For GitLab, Owner is the full namespace and may contain subgroups.
+type ChangeRequestRef struct {
+	Provider string
+	Host     string
+	Owner    string
+	Repo     string
+	Number   int
+}
+
+// CodeHostProvider is implemented by every code host notes can link to.
+type CodeHostProvider interface {
+	// Name returns the provider identifier stored on cached change requests.
+	Name() string
+	// FetchChangeRequest loads a pull/merge request and maps it into the cache model.
+	FetchChangeRequest(ref ChangeRequestRef, token string) (*models.PullRequest, error)
+	// ParseURL extracts a change request reference from a web URL.
+	ParseURL(rawURL string) (*ChangeRequestRef, error)
+	// ValidateToken checks the token and returns the username it belongs to.
+	ValidateToken(token string) (string, error)
+}
+
+// NormalizeProvider maps provider aliases onto the stored provider name.
+func NormalizeProvider(name string) string {
+	switch strings.ToLower(name) {
+	case "":
+		return models.ProviderGitHub
+	case models.ProviderForgejo:
+		return models.ProviderGitea
+	default:
+		return strings.ToLower(name)
+	}
+}
+
+// NewProvider returns the provider for name. baseURL is only used by
+// self-hosted providers and is empty for github.com.
+func NewProvider(name, baseURL string) (CodeHostProvider, error) {
+	switch NormalizeProvider(name) {
+	case models.ProviderGitHub:
+		if baseURL != "" {
+			return NewGitHubEnterpriseProvider(baseURL)
+		}
+		return NewGitHubProvider(NewGitHubService()), nil
+	case models.ProviderGitLab:
+		if baseURL == "" {
+			return nil, fmt.Errorf("base URL is required for GitLab")
+		}
+		return NewGitLabService(baseURL), nil
+	case models.ProviderGitea:
+		if baseURL == "" {
+			return nil, fmt.Errorf("base URL is required for Gitea")
+		}
+		return NewGiteaService(baseURL), nil
+	default:
+		return nil, fmt.Errorf("unsupported provider %q", name)
+	}
+}
+
+// ParseChangeRequestURL tries every known URL format and returns the first
+// match.
+func ParseChangeRequestURL(rawURL string) (*ChangeRequestRef, error) {
+	if ref, err := (&GitLabService{}).ParseURL(rawURL); err == nil {
+		return ref, nil
+	}
+	if ref, err := (&GiteaService{}).ParseURL(rawURL); err == nil {
+		return ref, nil
+	}
+	return (&GitHubProvider{}).ParseURL(rawURL)
+}
+
+// HostFromURL returns the host part of a base URL, e.g. "gitlab.example.com".
+func HostFromURL(rawURL string) (string, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Host == "" {
+		return "", fmt.Errorf("invalid URL %q", rawURL)
+	}
+	return strings.ToLower(parsed.Host), nil
+}
+
+// GitHubProvider adapts GitHubService to the CodeHostProvider interface, for
+// github.com or a GitHub Enterprise Server instance.
+type GitHubProvider struct {
+	service *GitHubService
+	host    string
+}
+
+func NewGitHubProvider(service *GitHubService) *GitHubProvider {
+	return &GitHubProvider{service: service, host: models.DefaultGitHubHost}
+}
+
+// NewGitHubEnterpriseProvider returns the provider for the GitHub Enterprise
+// Server instance at baseURL.
+func NewGitHubEnterpriseProvider(baseURL string) (*GitHubProvider, error) {
+	host, err := HostFromURL(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	return &GitHubProvider{service: NewGitHubEnterpriseService(baseURL), host: host}, nil
+}
+
+// Service returns the REST client for the provider's host.
+func (p *GitHubProvider) Service() *GitHubService {
+	return p.service
+}
+
+func (p *GitHubProvider) Name() string {
+	return models.ProviderGitHub
+}
+
+func (p *GitHubProvider) FetchChangeRequest(ref ChangeRequestRef, token string) (*models.PullRequest, error) {
+	prData, err := p.service.GetPullRequest(ref.Owner, ref.Repo, ref.Number, token)
+	if err != nil {
+		return nil, err
+	}
+	pr := PullRequestFromGithub(ref.Owner, ref.Repo, prData)
+	pr.Host = p.host
+	return &pr, nil
+}
+
+var githubPullURLPattern = regexp.MustCompile(`^/([^/]+)/([^/]+)/pull/(\d+)/?`)
+
+// ParseURL accepts pull request URLs on github.com and on any other host,
+// which is taken to be a GitHub Enterprise Server instance.
+func (p *GitHubProvider) ParseURL(rawURL string) (*ChangeRequestRef, error) {
+	parsed, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || parsed.Host == "" {
+		return nil, fmt.Errorf("not a GitHub pull request URL: %s", rawURL)
+	}
+
+	matches := githubPullURLPattern.FindStringSubmatch(parsed.Path)
+	if matches == nil {
+		return nil, fmt.Errorf("not a GitHub pull request URL: %s", rawURL)
+	}
+
+	number, _ := strconv.Atoi(matches[3])
+	return &ChangeRequestRef{
+		Provider: models.ProviderGitHub,
+		Host:     strings.ToLower(parsed.Host),
+		Owner:    matches[1],
+		Repo:     matches[2],
+		Number:   number,
+	}, nil
+}
+
+func (p *GitHubProvider) ValidateToken(token string) (string, error) {
+	return p.service.GetAuthenticatedUser(token)
+}
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"github-notes-backend/internal/database"
+	"github-notes-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// pullRequestKey is the unique key of the PR cache.
+var pullRequestKey = []clause.Column{
+	{Name: "provider"}, {Name: "host"}, {Name: "repo_owner"}, {Name: "repo_name"}, {Name: "number"},
+}
+
+// UpsertPullRequest stores pr, or refreshes the cached row with the same
+// provider, host, repository and number if another request got there first.
+// On return pr.ID is the id of the stored row.
+func UpsertPullRequest(pr *models.PullRequest) error {
+	return upsertPullRequest(database.DB, pr)
+}
+
+func upsertPullRequest(db *gorm.DB, pr *models.PullRequest) error {
+	if pr.ID == uuid.Nil {
+		pr.ID = uuid.New()
+	}
+
+	updates := clause.AssignmentColumns([]string{"title", "body", "author", "state", "draft", "head_sha",
+		"merged_at", "url", "private", "fetch_status", "last_error", "last_checked_at", "updated_at"})
+	updates = append(updates, clause.Assignment{
+		Column: clause.Column{Name: "repository_id"},
+		Value:  gorm.Expr("COALESCE(EXCLUDED.repository_id, pull_requests.repository_id)"),
+	})
+
+	if err := db.Clauses(clause.OnConflict{Columns: pullRequestKey, DoUpdates: updates}).Create(pr).Error; err != nil {
+		return err
+	}
+
+	// The insert may have turned into an update of an existing row
+	var stored models.PullRequest
+	if err := db.Where("provider = ? AND host = ? AND repo_owner = ? AND repo_name = ? AND number = ?",
+		pr.Provider, pr.Host, pr.RepoOwner, pr.RepoName, pr.Number).First(&stored).Error; err != nil {
+		return err
+	}
+	*pr = stored
+	return nil
+}
+
+// mergePullRequest moves every reference to the cached PR fromID over to
+// intoID and deletes fromID.
+func mergePullRequest(tx *gorm.DB, fromID, intoID uuid.UUID) error {
+	statements := []string{
+		// A link made through the API on either copy stays one
+		`UPDATE note_pr_links SET detected = FALSE WHERE pr_id = @into
+		 AND note_id IN (SELECT note_id FROM note_pr_links WHERE pr_id = @from AND NOT detected)`,
+		`INSERT INTO note_pr_links (note_id, pr_id, detected)
+		 SELECT note_id, @into, detected FROM note_pr_links WHERE pr_id = @from
+		 ON CONFLICT DO NOTHING`,
+		`DELETE FROM note_pr_links WHERE pr_id = @from`,
+		`UPDATE pr_comments SET pull_request_id = @into WHERE pull_request_id = @from`,
+		`UPDATE pull_request_events SET pull_request_id = @into WHERE pull_request_id = @from`,
+		`DELETE FROM note_pr_snapshots WHERE pull_request_id = @from
+		 AND note_id IN (SELECT note_id FROM note_pr_snapshots WHERE pull_request_id = @into)`,
+		`UPDATE note_pr_snapshots SET pull_request_id = @into WHERE pull_request_id = @from`,
+		`UPDATE notes SET published_pr_id = @into WHERE published_pr_id = @from`,
+		`DELETE FROM pull_requests WHERE id = @from`,
+	}
+	for _, statement := range statements {
+		if err := tx.Exec(statement, map[string]interface{}{"from": fromID, "into": intoID}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
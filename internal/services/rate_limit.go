@@ -0,0 +1,97 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rateLimitReserve is the part of a token's GitHub quota that background work
+// leaves untouched, so the user's own requests keep working.
+const rateLimitReserve = 500
+
+// backgroundResources are the GitHub API resources background work spends.
+// Other quotas, such as the 30 requests a minute of search, are far smaller
+// than the reserve and not touched by it, so they are not checked.
+var backgroundResources = map[string]bool{"core": true, "graphql": true}
+
+// rateLimit is the last quota GitHub reported for a token on one API resource
+// (core, graphql, search, ...).
+type rateLimit struct {
+	remaining int
+	reset     time.Time
+}
+
+var (
+	rateLimitsMu sync.Mutex
+	rateLimits   = make(map[string]rateLimit)
+)
+
+// recordRateLimit remembers the quota reported in GitHub's X-RateLimit headers.
+func recordRateLimit(token string, header http.Header) {
+	remaining, err := strconv.Atoi(header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return
+	}
+	reset, err := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return
+	}
+
+	rateLimitsMu.Lock()
+	defer rateLimitsMu.Unlock()
+	rateLimits[tokenKey(token)+":"+header.Get("X-RateLimit-Resource")] = rateLimit{
+		remaining: remaining,
+		reset:     time.Unix(reset, 0),
+	}
+}
+
+// NearRateLimit reports whether the core or GraphQL quota of token is down to
+// the reserve and has not reset yet.
+func NearRateLimit(token string) bool {
+	prefix := tokenKey(token) + ":"
+	now := time.Now()
+
+	rateLimitsMu.Lock()
+	defer rateLimitsMu.Unlock()
+	for key, limit := range rateLimits {
+		if !strings.HasPrefix(key, prefix) || !backgroundResources[strings.TrimPrefix(key, prefix)] {
+			continue
+		}
+		if now.After(limit.reset) {
+			delete(rateLimits, key)
+			continue
+		}
+		if limit.remaining <= rateLimitReserve {
+			return true
+		}
+	}
+	return false
+}
+
+// IsRateLimited reports whether err is GitHub refusing a request because the
+// rate limit was exceeded.
+func IsRateLimited(err error) bool {
+	statusErr, ok := err.(*StatusError)
+	if !ok {
+		return false
+	}
+	switch statusErr.StatusCode {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusForbidden:
+		// GitHub reports exhausted rate limits as 403 as well
+		return strings.Contains(strings.ToLower(statusErr.Message), "rate limit")
+	}
+	return false
+}
+
+// tokenKey avoids keeping raw tokens around as map keys.
+func tokenKey(token string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(token)))
+	return hex.EncodeToString(sum[:8])
+}
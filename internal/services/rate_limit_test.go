@@ -0,0 +1,45 @@
+package services
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func rateLimitHeader(resource string, remaining int, reset time.Time) http.Header {
+	header := http.Header{}
+	header.Set("X-RateLimit-Resource", resource)
+	header.Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+	header.Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+	return header
+}
+
+func TestNearRateLimit(t *testing.T) {
+	soon := time.Now().Add(time.Hour)
+	past := time.Now().Add(-time.Minute)
+
+	tests := []struct {
+		name    string
+		headers []http.Header
+		want    bool
+	}{
+		{"nothing recorded", nil, false},
+		{"plenty of core quota", []http.Header{rateLimitHeader("core", 4000, soon)}, false},
+		{"core down to the reserve", []http.Header{rateLimitHeader("core", rateLimitReserve, soon)}, true},
+		{"graphql down to the reserve", []http.Header{rateLimitHeader("graphql", 10, soon)}, true},
+		{"exhausted search quota", []http.Header{rateLimitHeader("search", 0, soon), rateLimitHeader("core", 4000, soon)}, false},
+		{"core quota already reset", []http.Header{rateLimitHeader("core", 0, past)}, false},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := "rate-limit-token-" + strconv.Itoa(i)
+			for _, header := range tt.headers {
+				recordRateLimit(token, header)
+			}
+			if got := NearRateLimit(token); got != tt.want {
+				t.Errorf("NearRateLimit = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github-notes-backend/internal/models"
+)
+
+var commitRefPattern = regexp.MustCompile(`^([A-Za-z0-9_.-]+)/([A-Za-z0-9_.-]+)@([0-9a-fA-F]{4,40})$`)
+
+// ParseCommitRef parses a commit reference written as "owner/repo@sha", where
+// sha may be abbreviated.
+func ParseCommitRef(ref string) (*models.Reference, error) {
+	matches := commitRefPattern.FindStringSubmatch(strings.TrimSpace(ref))
+	if matches == nil {
+		return nil, fmt.Errorf("invalid commit reference %q, expected owner/repo@sha", ref)
+	}
+
+	return &models.Reference{
+		Kind:      models.RefCommit,
+		RepoOwner: matches[1],
+		RepoName:  matches[2],
+		SHA:       strings.ToLower(matches[3]),
+	}, nil
+}
+
+// MaxContentReferences caps how many references ExtractReferences returns,
+// so a long note cannot trigger an unbounded number of fetches.
+const MaxContentReferences = 20
+
+var (
+	// Code spans and fenced blocks are skipped, as GitHub does
+	contentCodePattern = regexp.MustCompile("(?s)```.*?```|`[^`\n]*`")
+	contentURLPattern  = regexp.MustCompile(`https?://[^\s<>()\[\]]+`)
+	// githubItemURLPattern matches pull request and issue URLs on github.com
+	// and GitHub Enterprise Server hosts
+	githubItemURLPattern = regexp.MustCompile(`^https?://([A-Za-z0-9.-]+(?::\d+)?)/([A-Za-z0-9_.-]+)/([A-Za-z0-9_.-]+)/(pull|issues)/(\d+)(?:[/?#]|$)`)
+	repoRefPattern       = regexp.MustCompile(`(?:^|[^\w/.#-])([A-Za-z0-9_.-]+)/([A-Za-z0-9_.-]+)#(\d+)\b`)
+	bareRefPattern       = regexp.MustCompile(`(?:^|[^\w/#&])#(\d+)\b`)
+)
+
+// ExtractReferences finds the GitHub pull requests and issues referenced in
+// note content: PR and issue URLs on github.com or a GitHub Enterprise Server
+// host, owner/repo#N and, when defaultRepo is a GitHub repository, bare #N.
+// References are returned once each, in order of appearance, with their
+// identifiers normalized. Kind is empty for owner/repo#N and #N, which may
+// name either a pull request or an issue.
+func ExtractReferences(content string, defaultRepo *models.Repository) []models.Reference {
+	var refs []models.Reference
+	seen := make(map[string]bool)
+	add := func(ref models.Reference) {
+		ref.Provider = models.ProviderGitHub
+		ref.Host = models.NormalizeIdentifier(ref.Host)
+		ref.RepoOwner = models.NormalizeIdentifier(ref.RepoOwner)
+		ref.RepoName = models.NormalizeIdentifier(ref.RepoName)
+		key := fmt.Sprintf("%s/%s/%s#%d", ref.Host, ref.RepoOwner, ref.RepoName, ref.Number)
+		if ref.Number <= 0 || seen[key] || len(refs) >= MaxContentReferences {
+			return
+		}
+		seen[key] = true
+		refs = append(refs, ref)
+	}
+
+	content = contentCodePattern.ReplaceAllString(content, " ")
+
+	for _, rawURL := range contentURLPattern.FindAllString(content, -1) {
+		// Trailing punctuation ends the sentence, not the URL
+		matches := githubItemURLPattern.FindStringSubmatch(strings.TrimRight(rawURL, `.,;:!?'"`))
+		if matches == nil {
+			continue
+		}
+		number, _ := strconv.Atoi(matches[5])
+		kind := models.RefPullRequest
+		if matches[4] == "issues" {
+			kind = models.RefIssue
+		}
+		add(models.Reference{Kind: kind, Host: matches[1], RepoOwner: matches[2], RepoName: matches[3], Number: number})
+	}
+
+	// URLs may carry fragments such as #123 that are not references
+	content = contentURLPattern.ReplaceAllString(content, " ")
+
+	// owner/repo#N points into the default repository's host, as it would
+	// when written on that host
+	github := defaultRepo != nil && defaultRepo.Provider == models.ProviderGitHub
+	host := models.DefaultGitHubHost
+	if github {
+		host = defaultRepo.Host
+	}
+
+	for _, matches := range repoRefPattern.FindAllStringSubmatch(content, -1) {
+		number, _ := strconv.Atoi(matches[3])
+		add(models.Reference{Host: host, RepoOwner: matches[1], RepoName: matches[2], Number: number})
+	}
+
+	if github {
+		for _, matches := range bareRefPattern.FindAllStringSubmatch(content, -1) {
+			number, _ := strconv.Atoi(matches[1])
+			add(models.Reference{Host: defaultRepo.Host, RepoOwner: defaultRepo.Owner, RepoName: defaultRepo.Name, Number: number})
+		}
+	}
+
+	return refs
+}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github-notes-backend/internal/models"
+)
+
+// refString renders a reference as "kind host/owner/repo#N", kind being
+// blank for owner/repo#N and #N.
+func refString(ref models.Reference) string {
+	return fmt.Sprintf("%s %s/%s/%s#%d", ref.Kind, ref.Host, ref.RepoOwner, ref.RepoName, ref.Number)
+}
+
+func TestExtractReferences(t *testing.T) {
+	githubRepo := &models.Repository{Provider: models.ProviderGitHub, Host: "github.com", Owner: "acme", Name: "api"}
+	enterpriseRepo := &models.Repository{Provider: models.ProviderGitHub, Host: "ghe.example.com", Owner: "infra", Name: "tools"}
+	gitlabRepo := &models.Repository{Provider: models.ProviderGitLab, Host: "gitlab.com", Owner: "acme", Name: "api"}
+
+	tests := []struct {
+		name        string
+		content     string
+		defaultRepo *models.Repository
+		want        []string
+	}{
+		{
+			"PR and issue URLs",
+			"Fixes https://github.com/Acme/API/pull/12 and https://github.com/acme/web/issues/7/",
+			nil,
+			[]string{"pull_request github.com/acme/api#12", "issue github.com/acme/web#7"},
+		},
+		{
+			"URL fragment is not a bare reference",
+			"See https://github.com/acme/api/issues/3#issuecomment-99 and https://github.com/acme/api/pull/4#5",
+			githubRepo,
+			[]string{"issue github.com/acme/api#3", "pull_request github.com/acme/api#4"},
+		},
+		{
+			"trailing punctuation",
+			"Landed in https://github.com/acme/api/pull/12.",
+			nil,
+			[]string{"pull_request github.com/acme/api#12"},
+		},
+		{
+			"code spans and fenced blocks are skipped",
+			"Run `git log acme/api#1` and #2\n```\nacme/api#3 #4\n```\nthen acme/api#5",
+			githubRepo,
+			[]string{" github.com/acme/api#5", " github.com/acme/api#2"},
+		},
+		{
+			"GitHub Enterprise Server URL",
+			"https://ghe.example.com/Infra/Tools/pull/4",
+			nil,
+			[]string{"pull_request ghe.example.com/infra/tools#4"},
+		},
+		{
+			"references resolve on the default repository's host",
+			"infra/deploy#8 and #9",
+			enterpriseRepo,
+			[]string{" ghe.example.com/infra/deploy#8", " ghe.example.com/infra/tools#9"},
+		},
+		{
+			"no default repository ignores bare references",
+			"#5 and acme/api#6",
+			nil,
+			[]string{" github.com/acme/api#6"},
+		},
+		{
+			"non-GitHub default repository ignores bare references",
+			"#5 and acme/api#6",
+			gitlabRepo,
+			[]string{" github.com/acme/api#6"},
+		},
+		{
+			"duplicates are returned once",
+			"https://github.com/acme/api/pull/12, acme/api#12 and #12",
+			githubRepo,
+			[]string{"pull_request github.com/acme/api#12"},
+		},
+		{
+			"not references",
+			"issue#5, docs/guide/setup#6, &#39; and #0",
+			githubRepo,
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, ref := range ExtractReferences(tt.content, tt.defaultRepo) {
+				if ref.Provider != models.ProviderGitHub {
+					t.Errorf("provider = %q, want %q", ref.Provider, models.ProviderGitHub)
+				}
+				got = append(got, refString(ref))
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("ExtractReferences(%q) =\n%s\nwant\n%s", tt.content, strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestExtractReferencesCap(t *testing.T) {
+	var content strings.Builder
+	for n := 1; n <= MaxContentReferences+5; n++ {
+		fmt.Fprintf(&content, "acme/api#%d ", n)
+	}
+
+	refs := ExtractReferences(content.String(), nil)
+	if len(refs) != MaxContentReferences {
+		t.Fatalf("got %d references, want %d", len(refs), MaxContentReferences)
+	}
+	if last := refs[len(refs)-1].Number; last != MaxContentReferences {
+		t.Errorf("last reference = #%d, want the first %d in order", last, MaxContentReferences)
+	}
+}
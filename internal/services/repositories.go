@@ -0,0 +1,333 @@
+package services
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github-notes-backend/internal/database"
+	"github-notes-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// EnsureRepository returns the registry row for a repository, creating it when
+// it is missing. github.com repositories are populated from the GitHub API
+// when a token is available; otherwise, and for other hosts, a bare row
+// holding just the identifiers is created.
+func EnsureRepository(github *GitHubService, provider, host, owner, name, token string) (*models.Repository, error) {
+	var repo models.Repository
+	err := database.DB.Where("provider = ? AND host = ? AND LOWER(owner) = LOWER(?) AND LOWER(name) = LOWER(?)",
+		provider, host, owner, name).First(&repo).Error
+	if err == nil {
+		return &repo, nil
+	}
+
+	if models.IsGitHubDotCom(provider, host) && token != "" {
+		if data, err := github.GetRepository(owner, name, token); err == nil {
+			fetched := RepositoryFromGithub(data)
+			return UpsertRepository(&fetched)
+		}
+	}
+
+	repo = models.Repository{
+		Provider: provider,
+		Host:     host,
+		Owner:    owner,
+		Name:     name,
+	}
+	return UpsertRepository(&repo)
+}
+
+// UpsertRepository stores fresh repository metadata. Rows are matched by
+// GitHub id when known, then by provider, host, owner and name.
+func UpsertRepository(repo *models.Repository) (*models.Repository, error) {
+	var existing models.Repository
+	found := false
+
+	if repo.GithubID != nil {
+		found = database.DB.Where("github_id = ? AND host = ?", *repo.GithubID, repo.Host).First(&existing).Error == nil
+	}
+	if !found {
+		found = database.DB.Where("provider = ? AND host = ? AND LOWER(owner) = LOWER(?) AND LOWER(name) = LOWER(?)",
+			repo.Provider, repo.Host, repo.Owner, repo.Name).First(&existing).Error == nil
+	}
+
+	if !found {
+		if err := database.DB.Create(repo).Error; err != nil {
+			return nil, err
+		}
+		return repo, nil
+	}
+
+	existing.Owner = repo.Owner
+	existing.Name = repo.Name
+	if repo.GithubID != nil {
+		existing.GithubID = repo.GithubID
+		existing.DefaultBranch = repo.DefaultBranch
+		existing.Private = repo.Private
+		existing.Description = repo.Description
+		existing.URL = repo.URL
+	}
+	if err := database.DB.Save(&existing).Error; err != nil {
+		return nil, err
+	}
+	return &existing, nil
+}
+
+// SyncUserRepositories pulls up to maxRepos repositories the user can access
+// on GitHub into the registry and records them as the user's accessible
+// repositories. The accessible set is left untouched when GitHub cannot list
+// them all. It returns how many repositories were synced.
+func SyncUserRepositories(github *GitHubService, user *models.User, maxRepos int) (int, error) {
+	data, err := github.ListUserRepositories(user.GithubToken, maxRepos)
+	if err != nil {
+		return 0, err
+	}
+
+	repos := make([]models.Repository, 0, len(data))
+	seen := make(map[int64]bool, len(data))
+	for i := range data {
+		if seen[data[i].ID] {
+			continue
+		}
+		seen[data[i].ID] = true
+		repos = append(repos, RepositoryFromGithub(&data[i]))
+	}
+
+	ids, err := upsertRepositories(repos)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.UserRepository{}).Error; err != nil {
+			return err
+		}
+		if len(ids) > 0 {
+			rows := make([]models.UserRepository, len(ids))
+			for i, id := range ids {
+				rows[i] = models.UserRepository{UserID: user.ID, RepositoryID: id}
+			}
+			if err := tx.Create(&rows).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&models.User{}).Where("id = ?", user.ID).UpdateColumn("repositories_synced_at", now).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	user.RepositoriesSyncedAt = &now
+	return len(ids), nil
+}
+
+// ForgetUserRepositories drops the user's synced repositories, so they are
+// pulled again with the user's current GitHub token.
+func ForgetUserRepositories(userID uuid.UUID) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.UserRepository{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.User{}).Where("id = ?", userID).UpdateColumn("repositories_synced_at", nil).Error
+	})
+}
+
+// upsertRepositories stores fresh metadata of github.com repositories with a
+// single insert and returns their registry ids. Rows stored under another
+// spelling or an older name cannot be matched by the insert and are brought up
+// to date one by one first, as UpsertRepository would.
+func upsertRepositories(repos []models.Repository) ([]uuid.UUID, error) {
+	if len(repos) == 0 {
+		return nil, nil
+	}
+
+	githubIDs := make([]int64, len(repos))
+	names := make([][]interface{}, len(repos))
+	for i, repo := range repos {
+		githubIDs[i] = *repo.GithubID
+		names[i] = []interface{}{strings.ToLower(repo.Owner), strings.ToLower(repo.Name)}
+	}
+
+	var existing []models.Repository
+	if err := database.DB.Where("host = ?", models.DefaultGitHubHost).
+		Where(database.DB.Where("github_id IN ?", githubIDs).
+			Or("provider = ? AND (LOWER(owner), LOWER(name)) IN ?", models.ProviderGitHub, names)).
+		Find(&existing).Error; err != nil {
+		return nil, err
+	}
+
+	stored := make(map[string]bool, len(existing))
+	for _, repo := range existing {
+		stored[repo.FullName()] = true
+	}
+	for i := range existing {
+		repo := &existing[i]
+		for j := range repos {
+			fetched := &repos[j]
+			sameRepo := (repo.GithubID != nil && *repo.GithubID == *fetched.GithubID) ||
+				(strings.EqualFold(repo.Owner, fetched.Owner) && strings.EqualFold(repo.Name, fetched.Name))
+			if !sameRepo || stored[fetched.FullName()] {
+				continue
+			}
+			moved := *fetched
+			if _, err := UpsertRepository(&moved); err != nil {
+				return nil, err
+			}
+			stored[fetched.FullName()] = true
+		}
+	}
+
+	if err := database.DB.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "provider"}, {Name: "host"}, {Name: "owner"}, {Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"github_id", "default_branch", "private", "description", "url", "updated_at",
+		}),
+	}).Create(&repos).Error; err != nil {
+		return nil, err
+	}
+
+	var ids []uuid.UUID
+	if err := database.DB.Model(&models.Repository{}).
+		Where("host = ? AND github_id IN ?", models.DefaultGitHubHost, githubIDs).
+		Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// ResolveRepository re-reads a GitHub repository, by its stable id when known,
+// and rewrites the stored owner and name if it was renamed or transferred. It
+// reports whether the repository had moved.
+func ResolveRepository(github *GitHubService, repo *models.Repository, token string) (bool, error) {
+	var data *models.GithubRepository
+	var err error
+	if repo.GithubID != nil {
+		data, err = github.GetRepositoryByID(*repo.GithubID, token)
+	} else {
+		data, err = github.GetRepository(repo.Owner, repo.Name, token)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	moved := !strings.EqualFold(data.Owner.Login, repo.Owner) || !strings.EqualFold(data.Name, repo.Name)
+	if err := RenameRepository(repo.Owner, repo.Name, data); err != nil {
+		return false, err
+	}
+	return moved, nil
+}
+
+// RenameRepository moves everything stored under oldOwner/oldName on GitHub to
+// the repository's current owner and name. A registry row that already exists
+// for the new name absorbs the old one.
+func RenameRepository(oldOwner, oldName string, current *models.GithubRepository) error {
+	fetched := RepositoryFromGithub(current)
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		var canonical, old models.Repository
+		hasCanonical := tx.Where("github_id = ? AND host = ?", *fetched.GithubID, fetched.Host).First(&canonical).Error == nil
+		if !hasCanonical {
+			hasCanonical = tx.Where("provider = ? AND host = ? AND LOWER(owner) = LOWER(?) AND LOWER(name) = LOWER(?)",
+				fetched.Provider, fetched.Host, fetched.Owner, fetched.Name).First(&canonical).Error == nil
+		}
+		hasOld := tx.Where("provider = ? AND host = ? AND LOWER(owner) = LOWER(?) AND LOWER(name) = LOWER(?)",
+			fetched.Provider, fetched.Host, oldOwner, oldName).First(&old).Error == nil
+
+		switch {
+		case hasCanonical && hasOld && canonical.ID != old.ID:
+			// Both names were registered; fold the old row into the current one
+			for _, table := range []string{"notes", "pull_requests"} {
+				if err := tx.Table(table).Where("repository_id = ?", old.ID).
+					Update("repository_id", canonical.ID).Error; err != nil {
+					return err
+				}
+			}
+			if err := tx.Delete(&old).Error; err != nil {
+				return err
+			}
+		case !hasCanonical && hasOld:
+			canonical = old
+			hasCanonical = true
+		}
+
+		if hasCanonical {
+			fetched.ID = canonical.ID
+			fetched.CreatedAt = canonical.CreatedAt
+			if err := tx.Save(&fetched).Error; err != nil {
+				return err
+			}
+		} else if err := tx.Create(&fetched).Error; err != nil {
+			return err
+		}
+
+		if strings.EqualFold(oldOwner, fetched.Owner) && strings.EqualFold(oldName, fetched.Name) {
+			return nil
+		}
+
+		newOwner, newName := models.NormalizeIdentifier(fetched.Owner), models.NormalizeIdentifier(fetched.Name)
+
+		// A PR cached under both names keeps the row under the current name
+		var duplicates []struct {
+			FromID uuid.UUID
+			IntoID uuid.UUID
+		}
+		if err := tx.Raw(`SELECT old.id AS from_id, cur.id AS into_id
+			FROM pull_requests old
+			JOIN pull_requests cur ON cur.provider = old.provider AND cur.host = old.host AND cur.number = old.number
+			WHERE old.provider = ? AND old.host = ? AND old.repo_owner = ? AND old.repo_name = ?
+			  AND cur.repo_owner = ? AND cur.repo_name = ?`,
+			models.ProviderGitHub, fetched.Host, models.NormalizeIdentifier(oldOwner), models.NormalizeIdentifier(oldName),
+			newOwner, newName).Scan(&duplicates).Error; err != nil {
+			return err
+		}
+		for _, duplicate := range duplicates {
+			if err := mergePullRequest(tx, duplicate.FromID, duplicate.IntoID); err != nil {
+				return err
+			}
+		}
+
+		// Rewrite the denormalized owner/name columns of everything cached
+		// under the old name. Commits are only cached from github.com; PRs and
+		// issues may come from other hosts too.
+		for _, table := range []string{"pull_requests", "issues", "commits"} {
+			query := tx.Table(table).Where("LOWER(repo_owner) = LOWER(?) AND LOWER(repo_name) = LOWER(?)", oldOwner, oldName)
+			switch table {
+			case "pull_requests":
+				query = query.Where("provider = ? AND host = ?", models.ProviderGitHub, fetched.Host)
+			case "issues":
+				query = query.Where("host = ?", fetched.Host)
+			}
+			if err := query.Updates(map[string]interface{}{
+				"repo_owner": newOwner,
+				"repo_name":  newName,
+			}).Error; err != nil {
+				return err
+			}
+		}
+
+		log.Printf("Repository %s/%s moved to %s", oldOwner, oldName, fetched.FullName())
+		return nil
+	})
+}
+
+// followRepositoryMove is called by GitHubService when a request for
+// oldOwner/oldName was redirected to the repository with the given id.
+func (s *GitHubService) followRepositoryMove(oldOwner, oldName string, repositoryID int64, token string) {
+	if database.DB == nil {
+		return
+	}
+
+	data, err := s.GetRepositoryByID(repositoryID, token)
+	if err != nil {
+		log.Printf("Failed to resolve moved repository %s/%s: %v", oldOwner, oldName, err)
+		return
+	}
+
+	if err := RenameRepository(oldOwner, oldName, data); err != nil {
+		log.Printf("Failed to rename repository %s/%s: %v", oldOwner, oldName, err)
+	}
+}
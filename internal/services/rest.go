@@ -0,0 +1,96 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// restClient is the JSON-over-HTTP plumbing shared by the self-hosted code
+// host clients. authorize sets the provider specific authentication header.
+type restClient struct {
+	name      string
+	baseURL   string
+	client    *http.Client
+	authorize func(req *http.Request, token string)
+}
+
+func newRESTClient(name, baseURL string, authorize func(req *http.Request, token string)) restClient {
+	return restClient{
+		name:      name,
+		baseURL:   strings.TrimRight(baseURL, "/"),
+		client:    &http.Client{Timeout: 30 * time.Second},
+		authorize: authorize,
+	}
+}
+
+func (r *restClient) getJSON(path, token, notFound string, out interface{}) error {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return fmt.Errorf("%s token is required", r.name)
+	}
+
+	req, err := http.NewRequest("GET", r.baseURL+path, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	r.authorize(req, token)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "GitHub-Notes-App/1.0")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to make request to %s API: %w", r.name, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return &StatusError{StatusCode: resp.StatusCode, Message: r.statusMessage(resp.StatusCode, body, notFound)}
+	}
+
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", r.name, err)
+	}
+	return nil
+}
+
+// sitePath strips the path of the base URL from the path of a web URL, for
+// instances served under a subpath such as https://example.com/gitlab.
+func (r *restClient) sitePath(path string) string {
+	base, err := url.Parse(r.baseURL)
+	if err != nil || base.Path == "" || !strings.HasPrefix(path, base.Path+"/") {
+		return path
+	}
+	return strings.TrimPrefix(path, base.Path)
+}
+
+func (r *restClient) statusMessage(statusCode int, body []byte, notFound string) string {
+	switch statusCode {
+	case http.StatusNotFound:
+		return notFound
+	case http.StatusUnauthorized:
+		return fmt.Sprintf("%s authentication failed. Please check your %s token", r.name, r.name)
+	case http.StatusForbidden:
+		return fmt.Sprintf("%s API access forbidden. Your token may not have access to this repository", r.name)
+	case http.StatusTooManyRequests:
+		return fmt.Sprintf("%s API rate limit exceeded. Please try again later", r.name)
+	default:
+		// GitLab and Gitea both report errors in a "message" field
+		var apiErr struct {
+			Message interface{} `json:"message"`
+		}
+		if json.Unmarshal(body, &apiErr) == nil && apiErr.Message != nil {
+			return fmt.Sprintf("%s API error (status %d): %v", r.name, statusCode, apiErr.Message)
+		}
+		return fmt.Sprintf("%s API returned unexpected status %d", r.name, statusCode)
+	}
+}
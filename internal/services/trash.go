@@ -0,0 +1,49 @@
+package services
+
+import (
+	"time"
+
+	"github-notes-backend/internal/database"
+	"github-notes-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// PurgeNotes deletes notes for good, together with their links, snapshots,
+// tags and revisions.
+func PurgeNotes(ids []uuid.UUID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		for _, model := range []interface{}{
+			&models.NotePRLink{}, &models.NoteIssueLink{}, &models.NoteCommitLink{},
+			&models.NotePRSnapshot{}, &models.NoteTag{}, &models.NoteRevision{},
+		} {
+			if err := tx.Where("note_id IN ?", ids).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Unscoped().Where("id IN ?", ids).Delete(&models.Note{}).Error
+	})
+}
+
+// PurgeTrashedBefore deletes for good up to limit notes that were moved to
+// the trash before cutoff, returning how many it deleted.
+func PurgeTrashedBefore(cutoff time.Time, limit int) (int, error) {
+	var ids []uuid.UUID
+	if err := database.DB.Unscoped().Model(&models.Note{}).
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).
+		Order("deleted_at").
+		Limit(limit).
+		Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+
+	if err := PurgeNotes(ids); err != nil {
+		return 0, err
+	}
+	return len(ids), nil
+}